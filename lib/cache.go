@@ -0,0 +1,244 @@
+package subfwd
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+//Cache is a bounded, TTL-aware TXT and URI record
+//cache which wraps another Resolver. Answers are cached
+//for their record TTL when the wrapped resolver
+//reports one (see TTLResolver), or for DefaultTTL
+//otherwise. Not-found answers are cached for NegativeTTL.
+//Concurrent lookups of the same name are coalesced
+//into a single query, and when the wrapped resolver
+//fails, expired answers are served for up to StaleTTL
+//while they are refreshed in the background.
+//All other lookups are passed straight through.
+type Cache struct {
+	Resolver
+	DefaultTTL  time.Duration
+	NegativeTTL time.Duration
+	StaleTTL    time.Duration
+	Logf        func(string, ...interface{})
+	mut         sync.Mutex
+	size        int
+	lru         *list.List
+	entries     map[string]*list.Element
+	inflight    map[string]*cacheCall
+	hits        uint64
+	misses      uint64
+	stale       uint64
+}
+
+//CacheStats are the counters of a Cache
+type CacheStats struct {
+	Size   int
+	Hits   uint64
+	Misses uint64
+	Stale  uint64
+}
+
+//cacheEntry is keyed by record type and name
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	err     error
+	expires time.Time
+	failing bool
+}
+
+//cacheCall is an in-flight lookup
+type cacheCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+//cacheLookup performs an uncached lookup, returning the
+//value and its time-to-live (a negative ttl is unknown)
+type cacheLookup func() (interface{}, time.Duration, error)
+
+//NewCache creates a Cache which holds at most size records
+func NewCache(r Resolver, size int) *Cache {
+	return &Cache{
+		Resolver:    r,
+		DefaultTTL:  time.Minute,
+		NegativeTTL: 30 * time.Second,
+		Logf:        func(string, ...interface{}) {},
+		size:        size,
+		lru:         list.New(),
+		entries:     map[string]*list.Element{},
+		inflight:    map[string]*cacheCall{},
+	}
+}
+
+//LookupTXT returns the TXT records of the given name
+func (c *Cache) LookupTXT(name string) ([]string, error) {
+	v, _, err := c.lookup("TXT "+canonical(name), func() (interface{}, time.Duration, error) {
+		if tr, ok := c.Resolver.(TTLResolver); ok {
+			txts, ttl, err := tr.LookupTXTTTL(name)
+			return txts, ttl, err
+		}
+		txts, err := c.Resolver.LookupTXT(name)
+		return txts, -1, err
+	})
+	txts, _ := v.([]string)
+	return txts, err
+}
+
+//LookupURI returns the URI records of the given name, when
+//supported by the wrapped resolver (see URIResolver)
+func (c *Cache) LookupURI(name string) ([]*URI, time.Duration, error) {
+	ur, ok := c.Resolver.(URIResolver)
+	if !ok {
+		return nil, 0, errNoURI
+	}
+	v, ttl, err := c.lookup("URI "+canonical(name), func() (interface{}, time.Duration, error) {
+		uris, ttl, err := ur.LookupURI(name)
+		return uris, ttl, err
+	})
+	uris, _ := v.([]*URI)
+	return uris, ttl, err
+}
+
+//lookup returns the cached value of the given key, the
+//ttl returned is the remaining lifetime of the value
+func (c *Cache) lookup(key string, fn cacheLookup) (interface{}, time.Duration, error) {
+	e, fresh := c.get(key)
+	if fresh {
+		return e.value, time.Until(e.expires), e.err
+	}
+	//the resolver is already failing, dont wait on it
+	if e != nil && e.failing {
+		c.serveStale(e, nil)
+		go c.refresh(key, fn)
+		return e.value, 0, nil
+	}
+	v, ttl, err := c.refresh(key, fn)
+	if err != nil && !isNotFound(err) && e != nil {
+		c.serveStale(e, err)
+		return e.value, 0, nil
+	}
+	return v, ttl, err
+}
+
+//Stats returns the current cache counters
+func (c *Cache) Stats() CacheStats {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return CacheStats{
+		Size:   c.lru.Len(),
+		Hits:   c.hits,
+		Misses: c.misses,
+		Stale:  c.stale,
+	}
+}
+
+//refresh looks up the given key, joining any
+//lookup of the same key already in-flight
+func (c *Cache) refresh(key string, fn cacheLookup) (interface{}, time.Duration, error) {
+	c.mut.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mut.Unlock()
+		call.wg.Wait()
+		return call.value, 0, call.err
+	}
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.inflight[key] = call
+	c.mut.Unlock()
+
+	var ttl time.Duration
+	call.value, ttl, call.err = fn()
+	if ttl < 0 {
+		ttl = c.DefaultTTL
+	}
+	if call.err == nil {
+		c.set(&cacheEntry{key: key, value: call.value}, ttl)
+	} else if isNotFound(call.err) {
+		c.set(&cacheEntry{key: key, err: call.err}, c.NegativeTTL)
+	} else {
+		c.fail(key)
+	}
+
+	c.mut.Lock()
+	delete(c.inflight, key)
+	c.mut.Unlock()
+	call.wg.Done()
+	return call.value, ttl, call.err
+}
+
+//get returns a copy of the entry of the given key, which is
+//fresh when it has not yet expired. Expired positive
+//entries are kept for StaleTTL so they may be served
+//while the resolver is failing.
+func (c *Cache) get(key string) (*cacheEntry, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	e := elem.Value.(*cacheEntry)
+	now := time.Now()
+	cp := *e
+	if now.Before(e.expires) {
+		c.hits++
+		c.lru.MoveToFront(elem)
+		return &cp, true
+	}
+	c.misses++
+	if e.err != nil || !now.Before(e.expires.Add(c.StaleTTL)) {
+		c.remove(elem)
+		return nil, false
+	}
+	return &cp, false
+}
+
+//set replaces the entry of its key, values which
+//are not cached (ttl<=0) only remove the old entry
+func (c *Cache) set(e *cacheEntry, ttl time.Duration) {
+	e.expires = time.Now().Add(ttl)
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if elem, ok := c.entries[e.key]; ok {
+		c.remove(elem)
+	}
+	if ttl <= 0 || c.size <= 0 {
+		return
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	//evict least recently used
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+//fail marks the entry of the given key as failing
+func (c *Cache) fail(key string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).failing = true
+	}
+}
+
+func (c *Cache) serveStale(e *cacheEntry, err error) {
+	c.mut.Lock()
+	c.stale++
+	c.mut.Unlock()
+	age := time.Since(e.expires).Truncate(time.Second)
+	if err != nil {
+		c.Logf("Serving stale %s (expired %s ago): %s", e.key, age, err)
+	} else {
+		c.Logf("Serving stale %s (expired %s ago)", e.key, age)
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,273 @@
+package subfwd
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+//ttlResolver counts the lookups made on a FakeResolver,
+//reporting the given TTL and optionally failing them
+type ttlResolver struct {
+	*FakeResolver
+	ttl   time.Duration
+	delay time.Duration
+	mut   sync.Mutex
+	calls int
+	fail  bool
+}
+
+func (r *ttlResolver) LookupTXTTTL(name string) ([]string, time.Duration, error) {
+	r.mut.Lock()
+	r.calls++
+	fail, ttl := r.fail, r.ttl
+	r.mut.Unlock()
+	time.Sleep(r.delay)
+	if fail {
+		return nil, 0, &net.DNSError{Err: "timeout", Name: name, IsTimeout: true}
+	}
+	txts, err := r.FakeResolver.LookupTXT(name)
+	return txts, ttl, err
+}
+
+func (r *ttlResolver) count() int {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.calls
+}
+
+func (r *ttlResolver) setFail(fail bool) {
+	r.mut.Lock()
+	r.fail = fail
+	r.mut.Unlock()
+}
+
+func newTTLResolver(ttl time.Duration) *ttlResolver {
+	f := NewFakeResolver()
+	f.SetTXT("subfwd-a.example.com", "http://a.com")
+	f.SetTXT("subfwd-b.example.com", "http://b.com")
+	f.SetTXT("subfwd-c.example.com", "http://c.com")
+	return &ttlResolver{FakeResolver: f, ttl: ttl}
+}
+
+func TestCacheTTL(t *testing.T) {
+	r := newTTLResolver(50 * time.Millisecond)
+	c := NewCache(r, 10)
+	for i := 0; i < 3; i++ {
+		if txts, err := c.LookupTXT("subfwd-a.example.com"); err != nil || txts[0] != "http://a.com" {
+			t.Fatalf("got %v %v", txts, err)
+		}
+	}
+	if n := r.count(); n != 1 {
+		t.Errorf("expected 1 lookup within the TTL, got %d", n)
+	}
+	time.Sleep(60 * time.Millisecond)
+	c.LookupTXT("subfwd-a.example.com")
+	if n := r.count(); n != 2 {
+		t.Errorf("expected a lookup once the TTL expired, got %d", n)
+	}
+	if s := c.Stats(); s.Hits != 2 || s.Misses != 2 || s.Size != 1 {
+		t.Errorf("got stats %+v", s)
+	}
+}
+
+func TestCacheDefaultTTL(t *testing.T) {
+	//resolvers without TTLs use the default
+	f := NewFakeResolver()
+	f.SetTXT("subfwd-a.example.com", "http://a.com")
+	c := NewCache(f, 10)
+	c.DefaultTTL = time.Hour
+	c.LookupTXT("subfwd-a.example.com")
+	f.SetTXT("subfwd-a.example.com", "http://changed.com")
+	if txts, _ := c.LookupTXT("subfwd-a.example.com"); txts[0] != "http://a.com" {
+		t.Errorf("expected the cached record, got %v", txts)
+	}
+	//zero TTLs are not cached
+	r := newTTLResolver(0)
+	c = NewCache(r, 10)
+	c.LookupTXT("subfwd-a.example.com")
+	c.LookupTXT("subfwd-a.example.com")
+	if n := r.count(); n != 2 {
+		t.Errorf("expected 2 lookups, got %d", n)
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	r := newTTLResolver(time.Hour)
+	c := NewCache(r, 10)
+	c.NegativeTTL = 50 * time.Millisecond
+	for i := 0; i < 2; i++ {
+		if _, err := c.LookupTXT("subfwd-x.example.com"); !isNotFound(err) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	}
+	if n := r.count(); n != 1 {
+		t.Errorf("expected the negative answer to be cached, got %d lookups", n)
+	}
+	r.SetTXT("subfwd-x.example.com", "http://x.com")
+	time.Sleep(60 * time.Millisecond)
+	if txts, err := c.LookupTXT("subfwd-x.example.com"); err != nil || txts[0] != "http://x.com" {
+		t.Errorf("got %v %v", txts, err)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	r := newTTLResolver(time.Hour)
+	c := NewCache(r, 2)
+	c.LookupTXT("subfwd-a.example.com")
+	c.LookupTXT("subfwd-b.example.com")
+	c.LookupTXT("subfwd-a.example.com") //b is now least recently used
+	c.LookupTXT("subfwd-c.example.com")
+	if s := c.Stats(); s.Size != 2 {
+		t.Errorf("got size %d", s.Size)
+	}
+	n := r.count()
+	c.LookupTXT("subfwd-a.example.com")
+	c.LookupTXT("subfwd-c.example.com")
+	if r.count() != n {
+		t.Error("expected a and c to be cached")
+	}
+	c.LookupTXT("subfwd-b.example.com")
+	if r.count() != n+1 {
+		t.Error("expected b to be evicted")
+	}
+}
+
+func TestCacheStatsEndpoint(t *testing.T) {
+	s, f := newTestServer(t, Config{AdminHosts: []string{"admin.test"}, CacheSize: 10, CacheTTL: time.Minute, NegativeTTL: time.Minute})
+	f.SetTXT("subfwd-a.example.com", "http://a.com")
+	for i := 0; i < 3; i++ {
+		do(s, "GET", "http://a.example.com/")
+	}
+	//concurrent requests share the stats
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			do(s, "GET", "http://admin.test/stats")
+		}()
+	}
+	wg.Wait()
+	w := do(s, "GET", "http://admin.test/stats")
+	stats := struct{ Cache CacheStats }{}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Cache.Hits == 0 || stats.Cache.Misses == 0 || stats.Cache.Size == 0 {
+		t.Errorf("got stats %+v", stats.Cache)
+	}
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	r := newTTLResolver(time.Hour)
+	r.delay = 50 * time.Millisecond
+	c := NewCache(r, 10)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if txts, err := c.LookupTXT("subfwd-a.example.com"); err != nil || txts[0] != "http://a.com" {
+				t.Errorf("got %v %v", txts, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := r.count(); n != 1 {
+		t.Errorf("expected concurrent lookups to be coalesced, got %d", n)
+	}
+}
+
+func TestCacheStale(t *testing.T) {
+	r := newTTLResolver(20 * time.Millisecond)
+	c := NewCache(r, 10)
+	c.StaleTTL = time.Hour
+	logs := make(chan string, 10)
+	c.Logf = func(f string, args ...interface{}) {
+		select {
+		case logs <- f:
+		default:
+		}
+	}
+	c.LookupTXT("subfwd-a.example.com")
+	time.Sleep(30 * time.Millisecond)
+	r.setFail(true)
+	r.SetTXT("subfwd-a.example.com", "http://new.com")
+	//the first failure waits on the resolver, then serves stale
+	if txts, err := c.LookupTXT("subfwd-a.example.com"); err != nil || txts[0] != "http://a.com" {
+		t.Fatalf("got %v %v", txts, err)
+	}
+	if len(logs) != 1 {
+		t.Error("expected stale service to be logged")
+	}
+	//later lookups serve stale while refreshing in the background
+	n := r.count()
+	if txts, err := c.LookupTXT("subfwd-a.example.com"); err != nil || txts[0] != "http://a.com" {
+		t.Fatalf("got %v %v", txts, err)
+	}
+	r.setFail(false)
+	deadline := time.Now().Add(time.Second)
+	for {
+		txts, _ := c.LookupTXT("subfwd-a.example.com")
+		if txts[0] == "http://new.com" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the background refresh to update the record")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if r.count() <= n {
+		t.Error("expected a background refresh")
+	}
+	if s := c.Stats(); s.Stale < 2 {
+		t.Errorf("got stats %+v", s)
+	}
+	//stale answers are not served once StaleTTL has passed
+	c.StaleTTL = 0
+	time.Sleep(30 * time.Millisecond)
+	r.setFail(true)
+	if _, err := c.LookupTXT("subfwd-a.example.com"); err == nil || !isTimeout(err) {
+		t.Errorf("expected the resolver error, got %v", err)
+	}
+}
+
+//TestCacheRecovery checks failing entries are replaced by
+//answers which are not cached, rather than served stale
+func TestCacheRecovery(t *testing.T) {
+	r := newTTLResolver(20 * time.Millisecond)
+	c := NewCache(r, 10)
+	c.StaleTTL = time.Hour
+	c.NegativeTTL = 0
+	c.LookupTXT("subfwd-a.example.com")
+	c.LookupTXT("subfwd-b.example.com")
+	time.Sleep(30 * time.Millisecond)
+	r.setFail(true)
+	for _, name := range []string{"subfwd-a.example.com", "subfwd-b.example.com"} {
+		if txts, err := c.LookupTXT(name); err != nil || len(txts) != 1 {
+			t.Fatalf("expected a stale answer, got %v %v", txts, err)
+		}
+	}
+	r.mut.Lock()
+	r.ttl = 0
+	r.mut.Unlock()
+	r.SetTXT("subfwd-a.example.com", "http://new.com")
+	r.SetTXT("subfwd-b.example.com")
+	r.setFail(false)
+	//the first lookups refresh in the background
+	c.LookupTXT("subfwd-a.example.com")
+	c.LookupTXT("subfwd-b.example.com")
+	time.Sleep(20 * time.Millisecond)
+	if txts, err := c.LookupTXT("subfwd-a.example.com"); err != nil || txts[0] != "http://new.com" {
+		t.Errorf("got %v %v", txts, err)
+	}
+	if _, err := c.LookupTXT("subfwd-b.example.com"); !isNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if s := c.Stats(); s.Size != 0 {
+		t.Errorf("got stats %+v", s)
+	}
+}
@@ -0,0 +1,932 @@
+package subfwd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+//DefaultTrustAnchors are the DS records of the root zone KSKs
+var DefaultTrustAnchors = []string{
+	". 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+const (
+	typeDS     = dnsmessage.Type(43)
+	typeRRSIG  = dnsmessage.Type(46)
+	typeNSEC   = dnsmessage.Type(47)
+	typeDNSKEY = dnsmessage.Type(48)
+	typeNSEC3  = dnsmessage.Type(50)
+)
+
+//maxNSEC3Iterations bounds the hashing work of a
+//denial, zones using more are bogus (see RFC 9276)
+const maxNSEC3Iterations = 150
+
+//ValidationError is returned when the
+//DNSSEC validation of an answer fails
+type ValidationError struct {
+	Name   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return "DNSSEC validation failed for " + e.Name + ": " + e.Reason
+}
+
+//exchanger is implemented by resolvers which can send
+//raw queries (see Validator), NXDOMAIN responses are
+//returned along with a not found error
+type exchanger interface {
+	exchange(name string, qtype dnsmessage.Type, dnssec bool) (*dnsmessage.Message, error)
+}
+
+//Validator is a Resolver which validates the DNSSEC chain
+//of trust of TXT and URI answers, from its trust anchors down to
+//the answer itself. Only the records of the queried name (and
+//of its CNAME chain) answer a query. Answers which fail
+//validation (bogus) are always rejected, as are unsigned
+//answers from signed zones. Other unsigned answers, from zones
+//proven to be unsigned, are rejected when Require is set, or
+//when the name is within one of the Domains, and are otherwise
+//returned as-is.
+//CNAME lookups are not validated.
+type Validator struct {
+	Resolver
+	Require  bool
+	Domains  []string
+	ex       exchanger
+	anchors  map[string][]dsRecord
+	mut      sync.Mutex
+	zoneKeys map[string]*zoneKeys
+}
+
+type zoneKeys struct {
+	keys    []dnskey
+	expires time.Time
+}
+
+type dsRecord struct {
+	keyTag     uint16
+	algorithm  uint8
+	digestType uint8
+	digest     []byte
+}
+
+type dnskey struct {
+	flags     uint16
+	algorithm uint8
+	keyTag    uint16
+	publicKey []byte
+	rdata     []byte
+}
+
+type rrsig struct {
+	typeCovered dnsmessage.Type
+	algorithm   uint8
+	labels      uint8
+	origTTL     uint32
+	expiration  uint32
+	inception   uint32
+	keyTag      uint16
+	signer      string
+	signature   []byte
+}
+
+//rrset is a set of records sharing a name and type
+type rrset struct {
+	name   string
+	rrtype dnsmessage.Type
+	ttl    uint32
+	rdatas [][]byte
+	sigs   []*rrsig
+}
+
+//NewValidator creates a Validator which wraps the given
+//resolver, which must be able to send raw queries (i.e. an
+//UpstreamResolver or a DoHResolver). Trust anchors are DS
+//records, in presentation format, see DefaultTrustAnchors.
+func NewValidator(r Resolver, anchors []string) (*Validator, error) {
+	ex, ok := r.(exchanger)
+	if !ok {
+		return nil, errors.New("DNSSEC validation requires an upstream DNS server")
+	}
+	v := &Validator{
+		Resolver: r,
+		ex:       ex,
+		anchors:  map[string][]dsRecord{},
+		zoneKeys: map[string]*zoneKeys{},
+	}
+	for _, a := range anchors {
+		zone, ds, err := parseDS(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust anchor '%s': %s", a, err)
+		}
+		v.anchors[zone] = append(v.anchors[zone], ds)
+	}
+	if len(v.anchors) == 0 {
+		return nil, errors.New("DNSSEC validation requires a trust anchor")
+	}
+	return v, nil
+}
+
+//LookupTXT returns the validated TXT records of the given name
+func (v *Validator) LookupTXT(name string) ([]string, error) {
+	txts, _, err := v.LookupTXTTTL(name)
+	return txts, err
+}
+
+//LookupTXTTTL returns the validated TXT records of the
+//given name along with their smallest time-to-live
+func (v *Validator) LookupTXTTTL(name string) ([]string, time.Duration, error) {
+	msg, err := v.validate(name, dnsmessage.TypeTXT)
+	if err != nil {
+		return nil, 0, err
+	}
+	return txtAnswers(msg, name, "")
+}
+
+//LookupURI returns the validated URI records of the
+//given name along with their smallest time-to-live
+func (v *Validator) LookupURI(name string) ([]*URI, time.Duration, error) {
+	msg, err := v.validate(name, typeURI)
+	if err != nil {
+		return nil, 0, err
+	}
+	return uriAnswers(msg, name, "")
+}
+
+//validate queries the given name and type, and returns the
+//answer once the sets answering it (the records of the name,
+//or its CNAME chain and the records of its target) are valid
+func (v *Validator) validate(name string, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	msg, err := v.ex.exchange(name, qtype, true)
+	if err != nil {
+		return nil, err
+	}
+	sets := answerSets(msg)
+	owner := canonical(name) + "."
+	for i := 0; i < maxCNAMEChain; i++ {
+		set := sets[setKey(owner, qtype)]
+		if set == nil {
+			set = sets[setKey(owner, dnsmessage.TypeCNAME)]
+		}
+		if set == nil {
+			break //no (more) answers, see answerOwner
+		}
+		if err := v.check(name, set); err != nil {
+			return nil, err
+		}
+		if set.rrtype != dnsmessage.TypeCNAME {
+			break
+		}
+		if len(set.rdatas) != 1 {
+			return nil, &ValidationError{Name: name, Reason: owner + " has multiple CNAMEs"}
+		}
+		if owner, _, err = unpackName(set.rdatas[0]); err != nil {
+			return nil, &ValidationError{Name: name, Reason: err.Error()}
+		}
+	}
+	return msg, nil
+}
+
+//check validates a set answering the given name. Unsigned
+//sets are bogus when required, or when their zone is signed.
+func (v *Validator) check(name string, set *rrset) error {
+	if len(set.sigs) > 0 {
+		if err := v.verify(set, 0); err != nil {
+			return &ValidationError{Name: name, Reason: err.Error()}
+		}
+		return nil
+	}
+	if v.required(name) {
+		return &ValidationError{Name: name, Reason: "answer is unsigned"}
+	}
+	zone, secure, err := v.secure(set.name)
+	if err != nil {
+		return &ValidationError{Name: name, Reason: err.Error()}
+	}
+	if secure {
+		return &ValidationError{Name: name, Reason: set.name + " is unsigned, but " + zone + " is signed"}
+	}
+	return nil
+}
+
+//secure returns the zone of the given name, and whether the
+//zone is signed. DS sets are validated from the closest trust
+//anchor down to the name one label at a time, a zone is only
+//unsigned once its signed parent proves it has no DS records.
+func (v *Validator) secure(name string) (string, bool, error) {
+	name = canonical(name) + "."
+	zone := ""
+	for a := range v.anchors {
+		if within(name, a) && len(a) > len(zone) {
+			zone = a
+		}
+	}
+	if zone == "" {
+		return name, false, nil
+	}
+	labels := strings.Split(name, ".")
+	for i := labelCount(name) - labelCount(zone) - 1; i >= 0; i-- {
+		child := strings.Join(labels[i:], ".")
+		msg, err := v.ex.exchange(child, typeDS, true)
+		if msg == nil || (err != nil && !isNotFound(err)) {
+			return "", false, fmt.Errorf("DS lookup of %s failed: %s", child, err)
+		}
+		if set := answerSets(msg)[setKey(child, typeDS)]; set != nil {
+			if err := v.verify(set, 1); err != nil {
+				return "", false, err
+			}
+			zone = child
+			continue
+		}
+		cut, err := v.denied(zone, child, msg)
+		if err != nil {
+			return "", false, err
+		}
+		if cut {
+			return child, false, nil
+		}
+	}
+	return zone, true, nil
+}
+
+//denied returns whether the given name is a delegation from
+//the zone, once the NSEC or NSEC3 records of the zone prove
+//the name has no DS records (RFC 4035 5.2, RFC 5155 8.6)
+func (v *Validator) denied(zone, name string, msg *dnsmessage.Message) (bool, error) {
+	nsecs, nsec3s := []*rrset{}, []*rrset{}
+	for _, set := range rrsets(msg.Authorities) {
+		if (set.rrtype != typeNSEC && set.rrtype != typeNSEC3) || !within(set.name, zone) {
+			continue
+		}
+		//only the parent side of a delegation denies its DS
+		//records, not the NSEC record at the child's apex
+		sigs := []*rrsig{}
+		for _, sig := range set.sigs {
+			if sig.signer == zone {
+				sigs = append(sigs, sig)
+			}
+		}
+		set.sigs = sigs
+		if err := v.verify(set, 1); err != nil {
+			return false, err
+		}
+		if set.rrtype == typeNSEC {
+			nsecs = append(nsecs, set)
+		} else {
+			nsec3s = append(nsec3s, set)
+		}
+	}
+	for _, set := range nsecs {
+		for _, rdata := range set.rdatas {
+			next, n, err := unpackName(rdata)
+			if err != nil {
+				continue
+			}
+			if set.name == name {
+				if hasType(rdata[n:], typeDS) {
+					return false, fmt.Errorf("%s NSEC does not deny its DS records", name)
+				}
+				return hasType(rdata[n:], dnsmessage.TypeNS), nil
+			}
+			//the name does not exist, so is not a delegation
+			if covers(set.name, next, name, compareNames) {
+				return false, nil
+			}
+		}
+	}
+	if len(nsec3s) > 0 {
+		return denied3(zone, name, nsec3s)
+	}
+	return false, fmt.Errorf("%s has no proof of its missing DS records", name)
+}
+
+//denied3 is denied with NSEC3 records, an opt-out record
+//covering the name may hide an unsigned delegation
+func denied3(zone, name string, sets []*rrset) (bool, error) {
+	recs := []*nsec3{}
+	for _, set := range sets {
+		for _, rdata := range set.rdatas {
+			if rec, err := unpackNSEC3(set.name, rdata); err == nil && rec.hashAlg == 1 {
+				recs = append(recs, rec)
+			}
+		}
+	}
+	if len(recs) == 0 {
+		return false, fmt.Errorf("%s has no supported NSEC3 records", name)
+	}
+	if recs[0].iterations > maxNSEC3Iterations {
+		return false, fmt.Errorf("%s NSEC3 records have too many iterations", zone)
+	}
+	match := func(n string) *nsec3 {
+		h := nsec3Hash(n, recs[0].salt, recs[0].iterations)
+		for _, rec := range recs {
+			if rec.hash == h {
+				return rec
+			}
+		}
+		return nil
+	}
+	if rec := match(name); rec != nil {
+		if hasType(rec.types, typeDS) {
+			return false, fmt.Errorf("%s NSEC3 does not deny its DS records", name)
+		}
+		return hasType(rec.types, dnsmessage.TypeNS) && !hasType(rec.types, dnsmessage.TypeSOA), nil
+	}
+	//find the closest encloser, the next closer name must be covered
+	next := name
+	for {
+		ce := strings.SplitN(next, ".", 2)[1]
+		if match(ce) != nil {
+			break
+		}
+		if ce == zone {
+			return false, fmt.Errorf("%s has no NSEC3 closest encloser", name)
+		}
+		next = ce
+	}
+	h := nsec3Hash(next, recs[0].salt, recs[0].iterations)
+	for _, rec := range recs {
+		if covers(rec.hash, rec.next, h, strings.Compare) {
+			return rec.optOut, nil
+		}
+	}
+	return false, fmt.Errorf("%s has no proof of its missing DS records", name)
+}
+
+func (v *Validator) required(name string) bool {
+	if v.Require {
+		return true
+	}
+	name = canonical(name)
+	for _, d := range v.Domains {
+		d = canonical(d)
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+//verify checks that one of the signatures of the given
+//set was made by a trusted key of the signer's zone
+func (v *Validator) verify(set *rrset, depth int) error {
+	if len(set.sigs) == 0 {
+		return fmt.Errorf("%s %s is unsigned", set.name, set.rrtype)
+	}
+	if depth > 16 {
+		return errors.New("chain of trust is too long")
+	}
+	err := fmt.Errorf("%s %s has no valid signature", set.name, set.rrtype)
+	for _, sig := range set.sigs {
+		if !within(set.name, sig.signer) {
+			continue
+		}
+		if !sig.current() {
+			err = fmt.Errorf("%s %s signature is not currently valid", set.name, set.rrtype)
+			continue
+		}
+		keys, kerr := v.keys(sig.signer, depth)
+		if kerr != nil {
+			err = kerr
+			continue
+		}
+		for _, key := range keys {
+			if key.keyTag == sig.keyTag && key.algorithm == sig.algorithm &&
+				verifySig(set, sig, key) == nil {
+				return nil
+			}
+		}
+	}
+	return err
+}
+
+//keys returns the trusted keys of the given zone, the
+//zone's DNSKEY set must be signed by a key which matches
+//either a trust anchor or the validated DS set of the zone
+func (v *Validator) keys(zone string, depth int) ([]dnskey, error) {
+	v.mut.Lock()
+	zk, ok := v.zoneKeys[zone]
+	v.mut.Unlock()
+	if ok && time.Now().Before(zk.expires) {
+		return zk.keys, nil
+	}
+	//find the DS records of the zone
+	dss, ok := v.anchors[zone]
+	if !ok {
+		if zone == "." {
+			return nil, errors.New("no trust anchor")
+		}
+		msg, err := v.ex.exchange(zone, typeDS, true)
+		if err != nil {
+			return nil, fmt.Errorf("DS lookup of %s failed: %s", zone, err)
+		}
+		set := answerSets(msg)[setKey(zone, typeDS)]
+		if set == nil {
+			return nil, fmt.Errorf("%s has no DS records", zone)
+		}
+		if err := v.verify(set, depth+1); err != nil {
+			return nil, err
+		}
+		for _, rdata := range set.rdatas {
+			if ds, err := unpackDS(rdata); err == nil {
+				dss = append(dss, ds)
+			}
+		}
+	}
+	//find the DNSKEY records of the zone
+	msg, err := v.ex.exchange(zone, typeDNSKEY, true)
+	if err != nil {
+		return nil, fmt.Errorf("DNSKEY lookup of %s failed: %s", zone, err)
+	}
+	set := answerSets(msg)[setKey(zone, typeDNSKEY)]
+	if set == nil {
+		return nil, fmt.Errorf("%s has no DNSKEY records", zone)
+	}
+	keys := []dnskey{}
+	ksks := []dnskey{}
+	for _, rdata := range set.rdatas {
+		key, err := unpackDNSKEY(rdata)
+		if err != nil || key.flags&0x0100 == 0 {
+			continue //not a zone key
+		}
+		keys = append(keys, key)
+		for _, ds := range dss {
+			if ds.matches(zone, key) {
+				ksks = append(ksks, key)
+			}
+		}
+	}
+	if len(ksks) == 0 {
+		return nil, fmt.Errorf("%s has no DNSKEY matching its DS records", zone)
+	}
+	//the key set must be self-signed by a matching key
+	verified := false
+	for _, sig := range set.sigs {
+		if !sig.current() {
+			continue
+		}
+		for _, key := range ksks {
+			if key.keyTag == sig.keyTag && key.algorithm == sig.algorithm &&
+				verifySig(set, sig, key) == nil {
+				verified = true
+			}
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%s DNSKEY has no valid signature", zone)
+	}
+	v.mut.Lock()
+	v.zoneKeys[zone] = &zoneKeys{
+		keys:    keys,
+		expires: time.Now().Add(time.Duration(set.ttl) * time.Second),
+	}
+	v.mut.Unlock()
+	return keys, nil
+}
+
+//=============
+
+func setKey(name string, rrtype dnsmessage.Type) string {
+	return strings.ToLower(name) + " " + strconv.Itoa(int(rrtype))
+}
+
+//answerSets groups the answers of the given
+//message into sets along with their signatures
+func answerSets(msg *dnsmessage.Message) map[string]*rrset {
+	return rrsets(msg.Answers)
+}
+
+//rrsets groups records into sets along with their signatures
+func rrsets(rrs []dnsmessage.Resource) map[string]*rrset {
+	sets := map[string]*rrset{}
+	sigs := []*rrsig{}
+	sigNames := []string{}
+	for _, a := range rrs {
+		name := strings.ToLower(a.Header.Name.String())
+		var rdata []byte
+		switch body := a.Body.(type) {
+		case *dnsmessage.TXTResource:
+			for _, s := range body.TXT {
+				rdata = append(rdata, byte(len(s)))
+				rdata = append(rdata, s...)
+			}
+		case *dnsmessage.CNAMEResource:
+			rdata = nameWire(body.CNAME.String())
+		case *dnsmessage.UnknownResource:
+			if body.Type == typeRRSIG {
+				if sig, err := unpackRRSIG(body.Data); err == nil {
+					sigs = append(sigs, sig)
+					sigNames = append(sigNames, name)
+				}
+				continue
+			}
+			rdata = body.Data
+		default:
+			continue
+		}
+		k := setKey(name, a.Header.Type)
+		set, ok := sets[k]
+		if !ok {
+			set = &rrset{name: name, rrtype: a.Header.Type, ttl: a.Header.TTL}
+			sets[k] = set
+		}
+		if a.Header.TTL < set.ttl {
+			set.ttl = a.Header.TTL
+		}
+		set.rdatas = append(set.rdatas, rdata)
+	}
+	for i, sig := range sigs {
+		if set, ok := sets[setKey(sigNames[i], sig.typeCovered)]; ok {
+			set.sigs = append(set.sigs, sig)
+		}
+	}
+	return sets
+}
+
+//verifySig verifies the signature of the given set (RFC 4034 3.1.8.1)
+func verifySig(set *rrset, sig *rrsig, key dnskey) error {
+	//signed data is the signature's rdata (without the signature)...
+	data := make([]byte, 18)
+	binary.BigEndian.PutUint16(data[0:], uint16(sig.typeCovered))
+	data[2] = sig.algorithm
+	data[3] = sig.labels
+	binary.BigEndian.PutUint32(data[4:], sig.origTTL)
+	binary.BigEndian.PutUint32(data[8:], sig.expiration)
+	binary.BigEndian.PutUint32(data[12:], sig.inception)
+	binary.BigEndian.PutUint16(data[16:], sig.keyTag)
+	data = append(data, nameWire(sig.signer)...)
+	//...followed by the records in canonical form and order
+	owner := set.name
+	labels := strings.Split(strings.TrimSuffix(owner, "."), ".")
+	if n := labelCount(owner); int(sig.labels) > n {
+		return errors.New("signature has more labels than its owner")
+	} else if int(sig.labels) < n {
+		//wildcard expansion
+		owner = "*." + strings.Join(labels[n-int(sig.labels):], ".") + "."
+	}
+	ownerWire := nameWire(owner)
+	rdatas := append([][]byte{}, set.rdatas...)
+	sort.Slice(rdatas, func(i, j int) bool {
+		return bytes.Compare(rdatas[i], rdatas[j]) < 0
+	})
+	for i, rdata := range rdatas {
+		if i > 0 && bytes.Equal(rdata, rdatas[i-1]) {
+			continue
+		}
+		data = append(data, ownerWire...)
+		rr := make([]byte, 10)
+		binary.BigEndian.PutUint16(rr[0:], uint16(set.rrtype))
+		binary.BigEndian.PutUint16(rr[2:], uint16(dnsmessage.ClassINET))
+		binary.BigEndian.PutUint32(rr[4:], sig.origTTL)
+		binary.BigEndian.PutUint16(rr[8:], uint16(len(rdata)))
+		data = append(data, rr...)
+		data = append(data, rdata...)
+	}
+	return verifyData(key.algorithm, key.publicKey, data, sig.signature)
+}
+
+func verifyData(algorithm uint8, publicKey, data, signature []byte) error {
+	switch algorithm {
+	case 8, 10: //RSASHA256, RSASHA512
+		pub, err := rsaPublicKey(publicKey)
+		if err != nil {
+			return err
+		}
+		h, hash := sha256.New(), crypto.SHA256
+		if algorithm == 10 {
+			h, hash = sha512.New(), crypto.SHA512
+		}
+		h.Write(data)
+		return rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), signature)
+	case 13, 14: //ECDSAP256SHA256, ECDSAP384SHA384
+		curve, size := elliptic.P256(), 32
+		var digest []byte
+		if algorithm == 13 {
+			d := sha256.Sum256(data)
+			digest = d[:]
+		} else {
+			curve, size = elliptic.P384(), 48
+			d := sha512.Sum384(data)
+			digest = d[:]
+		}
+		if len(publicKey) != 2*size || len(signature) != 2*size {
+			return errors.New("invalid ECDSA key or signature")
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(publicKey[:size]),
+			Y:     new(big.Int).SetBytes(publicKey[size:]),
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	case 15: //ED25519
+		if len(publicKey) != ed25519.PublicKeySize {
+			return errors.New("invalid ED25519 key")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(publicKey), data, signature) {
+			return errors.New("invalid ED25519 signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %d", algorithm)
+}
+
+//rsaPublicKey unpacks an RSA key (RFC 3110 2)
+func rsaPublicKey(b []byte) (*rsa.PublicKey, error) {
+	if len(b) < 3 {
+		return nil, errors.New("invalid RSA key")
+	}
+	explen, off := int(b[0]), 1
+	if explen == 0 {
+		explen, off = int(b[1])<<8|int(b[2]), 3
+	}
+	if explen == 0 || explen > 4 || len(b) <= off+explen {
+		return nil, errors.New("invalid RSA key")
+	}
+	e := 0
+	for _, c := range b[off : off+explen] {
+		e = e<<8 | int(c)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(b[off+explen:]), E: e}, nil
+}
+
+func (ds dsRecord) matches(zone string, key dnskey) bool {
+	if ds.keyTag != key.keyTag || ds.algorithm != key.algorithm {
+		return false
+	}
+	data := append(nameWire(zone), key.rdata...)
+	var digest []byte
+	switch ds.digestType {
+	case 1:
+		d := sha1.Sum(data)
+		digest = d[:]
+	case 2:
+		d := sha256.Sum256(data)
+		digest = d[:]
+	case 4:
+		d := sha512.Sum384(data)
+		digest = d[:]
+	default:
+		return false
+	}
+	return bytes.Equal(digest, ds.digest)
+}
+
+//parseDS parses a DS record in presentation
+//format: <zone> <key tag> <algorithm> <digest type> <digest>
+func parseDS(s string) (string, dsRecord, error) {
+	ds := dsRecord{}
+	fields := strings.Fields(s)
+	if len(fields) < 5 {
+		return "", ds, errors.New("expected 5 fields")
+	}
+	zone := strings.ToLower(fields[0])
+	if !strings.HasSuffix(zone, ".") {
+		zone += "."
+	}
+	tag, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return "", ds, errors.New("invalid key tag")
+	}
+	alg, err := strconv.ParseUint(fields[2], 10, 8)
+	if err != nil {
+		return "", ds, errors.New("invalid algorithm")
+	}
+	dtype, err := strconv.ParseUint(fields[3], 10, 8)
+	if err != nil {
+		return "", ds, errors.New("invalid digest type")
+	}
+	digest, err := hex.DecodeString(strings.Join(fields[4:], ""))
+	if err != nil {
+		return "", ds, errors.New("invalid digest")
+	}
+	ds.keyTag = uint16(tag)
+	ds.algorithm = uint8(alg)
+	ds.digestType = uint8(dtype)
+	ds.digest = digest
+	return zone, ds, nil
+}
+
+func unpackDS(b []byte) (dsRecord, error) {
+	if len(b) < 5 {
+		return dsRecord{}, errors.New("invalid DS")
+	}
+	return dsRecord{
+		keyTag:     binary.BigEndian.Uint16(b),
+		algorithm:  b[2],
+		digestType: b[3],
+		digest:     b[4:],
+	}, nil
+}
+
+func unpackDNSKEY(b []byte) (dnskey, error) {
+	if len(b) < 5 {
+		return dnskey{}, errors.New("invalid DNSKEY")
+	}
+	return dnskey{
+		flags:     binary.BigEndian.Uint16(b),
+		algorithm: b[3],
+		keyTag:    keyTag(b),
+		publicKey: b[4:],
+		rdata:     b,
+	}, nil
+}
+
+func unpackRRSIG(b []byte) (*rrsig, error) {
+	if len(b) < 19 {
+		return nil, errors.New("invalid RRSIG")
+	}
+	signer, n, err := unpackName(b[18:])
+	if err != nil {
+		return nil, err
+	}
+	return &rrsig{
+		typeCovered: dnsmessage.Type(binary.BigEndian.Uint16(b)),
+		algorithm:   b[2],
+		labels:      b[3],
+		origTTL:     binary.BigEndian.Uint32(b[4:]),
+		expiration:  binary.BigEndian.Uint32(b[8:]),
+		inception:   binary.BigEndian.Uint32(b[12:]),
+		keyTag:      binary.BigEndian.Uint16(b[16:]),
+		signer:      signer,
+		signature:   b[18+n:],
+	}, nil
+}
+
+//current returns whether now is within the validity period of the signature
+func (sig *rrsig) current() bool {
+	now := uint32(time.Now().Unix())
+	return now >= sig.inception && now <= sig.expiration
+}
+
+//nsec3 is an NSEC3 record (RFC 5155 3), its owner
+//hash and next hash are in lower-case base32hex
+type nsec3 struct {
+	hashAlg    uint8
+	optOut     bool
+	iterations uint16
+	salt       []byte
+	hash       string
+	next       string
+	types      []byte
+}
+
+var base32Hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+func unpackNSEC3(owner string, b []byte) (*nsec3, error) {
+	if len(b) < 6 || len(b) < 6+int(b[4]) {
+		return nil, errors.New("invalid NSEC3")
+	}
+	rec := &nsec3{
+		hashAlg:    b[0],
+		optOut:     b[1]&1 == 1,
+		iterations: binary.BigEndian.Uint16(b[2:]),
+		salt:       b[5 : 5+int(b[4])],
+		hash:       strings.SplitN(owner, ".", 2)[0],
+	}
+	b = b[5+len(rec.salt):]
+	if len(b) < 1+int(b[0]) {
+		return nil, errors.New("invalid NSEC3")
+	}
+	rec.next = strings.ToLower(base32Hex.EncodeToString(b[1 : 1+int(b[0])]))
+	rec.types = b[1+int(b[0]):]
+	return rec, nil
+}
+
+//nsec3Hash returns the hashed owner label of a name (RFC 5155 5)
+func nsec3Hash(name string, salt []byte, iterations uint16) string {
+	h := sha1.Sum(append(nameWire(name), salt...))
+	for i := 0; i < int(iterations); i++ {
+		h = sha1.Sum(append(h[:], salt...))
+	}
+	return strings.ToLower(base32Hex.EncodeToString(h[:]))
+}
+
+//hasType returns whether the type bitmap
+//of an NSEC or NSEC3 record has the given type
+func hasType(bitmap []byte, t dnsmessage.Type) bool {
+	for len(bitmap) >= 2 {
+		window, n := int(bitmap[0]), int(bitmap[1])
+		if n > 32 || len(bitmap) < 2+n {
+			return false
+		}
+		if window == int(t)>>8 {
+			bit := int(t) & 0xFF
+			return bit/8 < n && bitmap[2+bit/8]&(0x80>>uint(bit%8)) != 0
+		}
+		bitmap = bitmap[2+n:]
+	}
+	return false
+}
+
+//covers returns whether the value is between the owner
+//and next values of a denial record, where the last
+//record of a zone wraps around to its first
+func covers(owner, next, v string, compare func(a, b string) int) bool {
+	if compare(owner, next) < 0 {
+		return compare(owner, v) < 0 && compare(v, next) < 0
+	}
+	return compare(owner, v) < 0 || compare(v, next) < 0
+}
+
+//compareNames orders names canonically (RFC 4034 6.1)
+func compareNames(a, b string) int {
+	al, bl := strings.Split(canonical(a), "."), strings.Split(canonical(b), ".")
+	for i, j := len(al)-1, len(bl)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(al[i], bl[j]); c != 0 {
+			return c
+		}
+	}
+	return len(al) - len(bl)
+}
+
+//within returns whether the name is within the zone
+func within(name, zone string) bool {
+	return zone == "." || name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+//labelCount returns the number of labels of a name
+func labelCount(name string) int {
+	if name = canonical(name); name == "" {
+		return 0
+	}
+	return strings.Count(name, ".") + 1
+}
+
+//keyTag computes the tag of a DNSKEY (RFC 4034 B)
+func keyTag(rdata []byte) uint16 {
+	ac := uint32(0)
+	for i, b := range rdata {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xFFFF
+	return uint16(ac & 0xFFFF)
+}
+
+//nameWire packs a name into its
+//canonical (lower-case) wire format
+func nameWire(name string) []byte {
+	b := []byte{}
+	for _, label := range strings.Split(canonical(name), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+//unpackName unpacks an uncompressed name, returning
+//it in lower-case along with its packed length
+func unpackName(b []byte) (string, int, error) {
+	labels := []string{}
+	off := 0
+	for {
+		if off >= len(b) {
+			return "", 0, errors.New("invalid name")
+		}
+		l := int(b[off])
+		off++
+		if l == 0 {
+			break
+		}
+		if l > 63 || off+l > len(b) {
+			return "", 0, errors.New("invalid name")
+		}
+		labels = append(labels, strings.ToLower(string(b[off:off+l])))
+		off += l
+	}
+	return strings.Join(labels, ".") + ".", off, nil
+}
@@ -0,0 +1,474 @@
+package subfwd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+//signedServer is an exchanger serving signed
+//(ECDSAP256SHA256) and unsigned zones, as would
+//a validating-aware recursive resolver
+type signedServer struct {
+	keys  map[string]*ecdsa.PrivateKey
+	zones []string
+	recs  map[string][]dnsmessage.Resource
+	//strip removes the signatures of these names
+	strip map[string]bool
+	//replay answers queries of a name with the records of another
+	replay map[string]string
+	//nsec3 denies DS records with NSEC3 instead of NSEC records,
+	//optOut delegations are missing from the zone, and covered
+	//by an opt-out NSEC3 record when true
+	nsec3  bool
+	optOut map[string]bool
+	//deny spoofs the DS denial of these names, without a
+	//proof ("none") or with the NSEC of the child's apex ("apex")
+	deny map[string]string
+	//expired signs the records of these names with expired
+	//signatures, and labels with the given label count
+	expired map[string]bool
+	labels  map[string]byte
+}
+
+func newSignedServer(t *testing.T, signed []string, unsigned []string) *signedServer {
+	z := &signedServer{
+		keys:    map[string]*ecdsa.PrivateKey{},
+		recs:    map[string][]dnsmessage.Resource{},
+		strip:   map[string]bool{},
+		replay:  map[string]string{},
+		optOut:  map[string]bool{},
+		deny:    map[string]string{},
+		expired: map[string]bool{},
+		labels:  map[string]byte{},
+	}
+	for _, zone := range signed {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		z.keys[zone] = k
+	}
+	z.zones = append(signed, unsigned...)
+	return z
+}
+
+//zoneOf returns the closest zone enclosing the given name
+func (z *signedServer) zoneOf(name string) string {
+	best := ""
+	for _, zone := range z.zones {
+		if (name == zone || zone == "." || strings.HasSuffix(name, "."+zone)) && len(zone) > len(best) {
+			best = zone
+		}
+	}
+	return best
+}
+
+func (z *signedServer) keyRdata(zone string) []byte {
+	pub := z.keys[zone].PublicKey
+	return append([]byte{1, 1, 3, 13}, append(pub.X.FillBytes(make([]byte, 32)), pub.Y.FillBytes(make([]byte, 32))...)...)
+}
+
+func (z *signedServer) dsRdata(zone string) []byte {
+	d := sha256.Sum256(append(nameWire(zone), z.keyRdata(zone)...))
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b, keyTag(z.keyRdata(zone)))
+	b[2], b[3] = 13, 2
+	return append(b, d[:]...)
+}
+
+//anchor returns the DS record of the given zone
+func (z *signedServer) anchor(zone string) string {
+	return zone + " " + fmt.Sprint(keyTag(z.keyRdata(zone))) + " 13 2 " + hex.EncodeToString(z.dsRdata(zone)[4:])
+}
+
+//add adds a record, which is signed when served from a signed zone
+func (z *signedServer) add(rr dnsmessage.Resource) {
+	name := strings.ToLower(rr.Header.Name.String())
+	z.recs[name] = append(z.recs[name], rr)
+}
+
+//sign returns the RRSIG of the records of a name and type
+func (z *signedServer) sign(zone string, rrs []dnsmessage.Resource) dnsmessage.Resource {
+	msg := &dnsmessage.Message{Answers: rrs}
+	set := answerSets(msg)[setKey(strings.ToLower(rrs[0].Header.Name.String()), rrs[0].Header.Type)]
+	now := time.Now()
+	if z.expired[set.name] {
+		now = now.Add(-3 * time.Hour)
+	}
+	head := make([]byte, 18)
+	binary.BigEndian.PutUint16(head[0:], uint16(set.rrtype))
+	head[2] = 13
+	head[3] = byte(labelCount(set.name))
+	if l, ok := z.labels[set.name]; ok {
+		head[3] = l
+	}
+	binary.BigEndian.PutUint32(head[4:], 300)
+	binary.BigEndian.PutUint32(head[8:], uint32(now.Add(time.Hour).Unix()))
+	binary.BigEndian.PutUint32(head[12:], uint32(now.Add(-time.Hour).Unix()))
+	binary.BigEndian.PutUint16(head[16:], keyTag(z.keyRdata(zone)))
+	head = append(head, nameWire(zone)...)
+	data := append([]byte{}, head...)
+	rdatas := set.rdatas
+	sort.Slice(rdatas, func(i, j int) bool { return bytes.Compare(rdatas[i], rdatas[j]) < 0 })
+	for _, rdata := range rdatas {
+		rr := make([]byte, 10)
+		binary.BigEndian.PutUint16(rr[0:], uint16(set.rrtype))
+		binary.BigEndian.PutUint16(rr[2:], uint16(dnsmessage.ClassINET))
+		binary.BigEndian.PutUint32(rr[4:], 300)
+		binary.BigEndian.PutUint16(rr[8:], uint16(len(rdata)))
+		data = append(data, nameWire(set.name)...)
+		data = append(data, rr...)
+		data = append(data, rdata...)
+	}
+	h := sha256.Sum256(data)
+	r, s, _ := ecdsa.Sign(rand.Reader, z.keys[zone], h[:])
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: rrs[0].Header.Name, Type: typeRRSIG, Class: dnsmessage.ClassINET, TTL: 300},
+		Body:   &dnsmessage.UnknownResource{Type: typeRRSIG, Data: append(head, sig...)},
+	}
+}
+
+//answer returns the records of a name and type, along
+//with their signature when their zone is signed
+func (z *signedServer) answer(zone string, rrs []dnsmessage.Resource) []dnsmessage.Resource {
+	if len(rrs) == 0 {
+		return nil
+	}
+	if _, ok := z.keys[zone]; ok && !z.strip[strings.ToLower(rrs[0].Header.Name.String())] {
+		rrs = append(rrs, z.sign(zone, rrs))
+	}
+	return rrs
+}
+
+func (z *signedServer) rr(name string, rrtype dnsmessage.Type, rdata []byte) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: rrtype, Class: dnsmessage.ClassINET, TTL: 300},
+		Body:   &dnsmessage.UnknownResource{Type: rrtype, Data: rdata},
+	}
+}
+
+func (z *signedServer) soa(zone string) dnsmessage.Resource {
+	n := dnsmessage.MustNewName(zone)
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: n, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET, TTL: 300},
+		Body:   &dnsmessage.SOAResource{NS: n, MBox: n, Serial: 1, Refresh: 60, Retry: 60, Expire: 60, MinTTL: 60},
+	}
+}
+
+func (z *signedServer) exchange(name string, qtype dnsmessage.Type, dnssec bool) (*dnsmessage.Message, error) {
+	name = canonical(name) + "."
+	msg := &dnsmessage.Message{Header: dnsmessage.Header{Response: true}}
+	zone := z.zoneOf(name)
+	switch {
+	case qtype == typeDNSKEY && name == zone && z.keys[zone] != nil:
+		msg.Answers = z.answer(zone, []dnsmessage.Resource{z.rr(name, typeDNSKEY, z.keyRdata(zone))})
+	case qtype == typeDS:
+		//served by the parent zone of a zone cut
+		parent := zone
+		if name == zone {
+			parent = z.zoneOf(strings.SplitN(name, ".", 2)[1])
+		}
+		if name == zone && z.keys[zone] != nil && z.deny[name] == "" {
+			msg.Answers = z.answer(parent, []dnsmessage.Resource{z.rr(name, typeDS, z.dsRdata(zone))})
+		} else {
+			msg.Authorities = append([]dnsmessage.Resource{z.soa(parent)}, z.denial(parent, name)...)
+		}
+	case qtype == dnsmessage.TypeSOA && name == zone:
+		msg.Answers = z.answer(zone, []dnsmessage.Resource{z.soa(zone)})
+	case qtype == dnsmessage.TypeSOA:
+		msg.Authorities = []dnsmessage.Resource{z.soa(zone)}
+	default:
+		//follow CNAMEs, as does a recursive resolver
+		owner := name
+		for i := 0; i < 8; i++ {
+			rrs := z.recs[owner]
+			if r, ok := z.replay[owner]; ok {
+				rrs = z.recs[r]
+			}
+			if len(rrs) == 0 {
+				break
+			}
+			if c, ok := rrs[0].Body.(*dnsmessage.CNAMEResource); ok {
+				msg.Answers = append(msg.Answers, z.answer(z.zoneOf(owner), rrs)...)
+				owner = strings.ToLower(c.CNAME.String())
+				continue
+			}
+			matching := []dnsmessage.Resource{}
+			for _, rr := range rrs {
+				if rr.Header.Type == qtype {
+					matching = append(matching, rr)
+				}
+			}
+			msg.Answers = append(msg.Answers, z.answer(z.zoneOf(owner), matching)...)
+			break
+		}
+		if len(msg.Answers) == 0 {
+			return msg, notFound(name, "")
+		}
+	}
+	return msg, nil
+}
+
+//denial returns the records of the zone proving the name has no
+//DS records, the name is missing unless it has records or is a zone
+func (z *signedServer) denial(zone, name string) []dnsmessage.Resource {
+	if z.keys[zone] == nil || z.deny[name] == "none" {
+		return nil
+	}
+	if z.deny[name] == "apex" {
+		bitmap := typeBitmap(dnsmessage.TypeNS, dnsmessage.TypeSOA, typeRRSIG, typeNSEC, typeDNSKEY)
+		return z.answer(name, []dnsmessage.Resource{z.rr(name, typeNSEC, append(nameWire("~."+name), bitmap...))})
+	}
+	types := []dnsmessage.Type{dnsmessage.TypeTXT, typeRRSIG, typeNSEC}
+	if name == z.zoneOf(name) {
+		types = []dnsmessage.Type{dnsmessage.TypeNS}
+	}
+	_, optOut := z.optOut[name]
+	exists := (len(z.recs[name]) > 0 || name == z.zoneOf(name)) && !optOut
+	if !z.nsec3 {
+		if !exists {
+			//covered by the record of the zone's apex
+			return z.answer(zone, []dnsmessage.Resource{z.rr(zone, typeNSEC, append(nameWire("~."+name), typeBitmap(dnsmessage.TypeSOA)...))})
+		}
+		return z.answer(zone, []dnsmessage.Resource{z.rr(name, typeNSEC, append(nameWire("~."+name), typeBitmap(types...)...))})
+	}
+	hash := func(n string) *big.Int {
+		b, _ := base32Hex.DecodeString(strings.ToUpper(nsec3Hash(n, []byte{0xab}, 1)))
+		return new(big.Int).SetBytes(b)
+	}
+	rec := func(owner, next *big.Int, optOut bool, types ...dnsmessage.Type) []dnsmessage.Resource {
+		rdata := []byte{1, 0, 0, 1, 1, 0xab, 20}
+		if optOut {
+			rdata[1] = 1
+		}
+		rdata = append(rdata, next.FillBytes(make([]byte, 20))...)
+		label := strings.ToLower(base32Hex.EncodeToString(owner.FillBytes(make([]byte, 20))))
+		return z.answer(zone, []dnsmessage.Resource{z.rr(label+"."+zone, typeNSEC3, append(rdata, typeBitmap(types...)...))})
+	}
+	one := big.NewInt(1)
+	if exists {
+		h := hash(name)
+		return rec(h, new(big.Int).Add(h, one), false, types...)
+	}
+	//the closest encloser is the zone's apex, which
+	//must not cover the next closer name
+	labels := strings.Split(name, ".")
+	next := strings.Join(labels[len(labels)-labelCount(zone)-2:], ".")
+	apex, h := hash(zone), hash(next)
+	return append(rec(apex, new(big.Int).Add(apex, one), false, dnsmessage.TypeSOA),
+		rec(new(big.Int).Sub(h, one), new(big.Int).Add(h, one), z.optOut[name])...)
+}
+
+//typeBitmap returns the NSEC type bitmap of the given types
+func typeBitmap(types ...dnsmessage.Type) []byte {
+	b := make([]byte, 34)
+	n := 0
+	for _, t := range types {
+		b[2+int(t)/8] |= 0x80 >> uint(t%8)
+		if int(t)/8+1 > n {
+			n = int(t)/8 + 1
+		}
+	}
+	b[1] = byte(n)
+	return b[:2+n]
+}
+
+func (z *signedServer) LookupTXT(name string) ([]string, error) {
+	return nil, notFound(name, "")
+}
+
+func (z *signedServer) LookupCNAME(name string) (string, error) {
+	return "", notFound(name, "")
+}
+
+//newSignedFixture creates a signed com. and example.com., with
+//insecure delegations to insecure.com. and optout.com., the
+//latter only proven insecure by an NSEC3 opt-out record
+func newSignedFixture(t *testing.T) (*signedServer, *Validator) {
+	z := newSignedServer(t, []string{"com.", "example.com."}, []string{"insecure.com.", "optout.com."})
+	z.optOut["optout.com."] = true
+	z.add(txtRR("subfwd-a.example.com.", 300, "http://signed.com"))
+	z.add(txtRR("subfwd-b.example.com.", 300, "http://other.com"))
+	z.add(txtRR("subfwd-u.insecure.com.", 300, "http://unsigned.com"))
+	z.add(txtRR("subfwd-o.optout.com.", 300, "http://optout.com"))
+	z.add(cnameRR("subfwd-c.example.com.", "subfwd-a.example.com."))
+	z.add(cnameRR("subfwd-d.example.com.", "subfwd-u.insecure.com."))
+	z.add(cnameRR("subfwd-e.insecure.com.", "subfwd-a.example.com."))
+	v, err := NewValidator(z, []string{z.anchor("com.")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z, v
+}
+
+func TestValidator(t *testing.T) {
+	for _, nsec3 := range []bool{false, true} {
+		z, v := newSignedFixture(t)
+		z.nsec3 = nsec3
+		for name, want := range map[string]string{
+			"subfwd-a.example.com":  "http://signed.com",
+			"subfwd-c.example.com":  "http://signed.com",
+			"subfwd-u.insecure.com": "http://unsigned.com",
+			"subfwd-d.example.com":  "http://unsigned.com",
+			"subfwd-e.insecure.com": "http://signed.com",
+		} {
+			txts, err := v.LookupTXT(name)
+			if err != nil || len(txts) != 1 || txts[0] != want {
+				t.Errorf("nsec3=%v %s: got %v %v, want %s", nsec3, name, txts, err, want)
+			}
+		}
+		if _, err := v.LookupTXT("subfwd-x.example.com"); !isNotFound(err) {
+			t.Errorf("nsec3=%v: expected not found, got %v", nsec3, err)
+		}
+		//opt-out only proves an unsigned delegation with NSEC3
+		txts, err := v.LookupTXT("subfwd-o.optout.com")
+		if nsec3 && (err != nil || len(txts) != 1 || txts[0] != "http://optout.com") {
+			t.Errorf("opt-out: got %v %v", txts, err)
+		} else if _, ok := err.(*ValidationError); !nsec3 && !ok {
+			t.Errorf("expected a validation error, got %v %v", txts, err)
+		}
+	}
+}
+
+//TestValidatorDenial checks an unsigned answer is only accepted
+//once its zone is proven to be unsigned by its signed parent
+func TestValidatorDenial(t *testing.T) {
+	for _, c := range []struct {
+		nsec3            bool
+		zone, name, deny string
+	}{
+		//the signatures of a signed zone are stripped, and
+		//its DS records are denied without a proof
+		{false, "example.com.", "subfwd-a.example.com", "none"},
+		{true, "example.com.", "subfwd-a.example.com", "none"},
+		//or with the NSEC record of the zone's own apex
+		{false, "example.com.", "subfwd-a.example.com", "apex"},
+		{true, "example.com.", "subfwd-a.example.com", "apex"},
+		//an unsigned zone without a proof
+		{false, "insecure.com.", "subfwd-u.insecure.com", "none"},
+		//an unsigned zone covered by an NSEC3 record without opt-out
+		{true, "optout.com.", "subfwd-o.optout.com", ""},
+	} {
+		z, v := newSignedFixture(t)
+		z.nsec3 = c.nsec3
+		z.deny[c.zone] = c.deny
+		z.optOut["optout.com."] = false
+		z.strip["subfwd-a.example.com."] = true
+		txts, err := v.LookupTXT(c.name)
+		if _, ok := err.(*ValidationError); !ok {
+			t.Errorf("%+v: expected a validation error, got %v %v", c, txts, err)
+		}
+	}
+}
+
+func TestValidatorBogus(t *testing.T) {
+	z, v := newSignedFixture(t)
+	//signed with another key
+	other := newSignedServer(t, []string{"example.com."}, nil)
+	z.recs["subfwd-f.example.com."] = []dnsmessage.Resource{txtRR("subfwd-f.example.com.", 300, "http://evil.com")}
+	z.recs["subfwd-f.example.com."] = append(z.recs["subfwd-f.example.com."], other.sign("example.com.", z.recs["subfwd-f.example.com."]))
+	z.strip["subfwd-f.example.com."] = true
+	//tampered with after signing
+	z.recs["subfwd-g.example.com."] = []dnsmessage.Resource{txtRR("subfwd-g.example.com.", 300, "http://signed.com")}
+	sig := z.sign("example.com.", z.recs["subfwd-g.example.com."])
+	z.recs["subfwd-g.example.com."] = []dnsmessage.Resource{txtRR("subfwd-g.example.com.", 300, "http://evil.com"), sig}
+	z.strip["subfwd-g.example.com."] = true
+	//signatures stripped from a signed zone
+	z.strip["subfwd-b.example.com."] = true
+	for _, name := range []string{"subfwd-f.example.com", "subfwd-g.example.com", "subfwd-b.example.com"} {
+		txts, err := v.LookupTXT(name)
+		if _, ok := err.(*ValidationError); !ok {
+			t.Errorf("%s: expected a validation error, got %v %v", name, txts, err)
+		}
+	}
+	//a signature claiming more labels than its owner
+	z.recs["subfwd-l.example.com."] = []dnsmessage.Resource{txtRR("subfwd-l.example.com.", 300, "http://evil.com")}
+	z.labels["subfwd-l.example.com."] = 9
+	if txts, err := v.LookupTXT("subfwd-l.example.com"); err == nil {
+		t.Errorf("expected a validation error, got %v", txts)
+	}
+	//a CNAME to a stripped record
+	z.add(cnameRR("subfwd-h.insecure.com.", "subfwd-b.example.com."))
+	if txts, err := v.LookupTXT("subfwd-h.insecure.com"); err == nil {
+		t.Errorf("expected a validation error, got %v", txts)
+	}
+	//the wrong trust anchor
+	v2, err := NewValidator(z, DefaultTrustAnchors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txts, err := v2.LookupTXT("subfwd-a.example.com"); err == nil {
+		t.Errorf("expected a validation error, got %v", txts)
+	}
+}
+
+func TestValidatorExpiredKeys(t *testing.T) {
+	z, v := newSignedFixture(t)
+	z.expired["example.com."] = true
+	if txts, err := v.LookupTXT("subfwd-a.example.com"); err == nil {
+		t.Errorf("expected an expired key set to be rejected, got %v", txts)
+	}
+}
+
+func TestValidatorReplay(t *testing.T) {
+	z, v := newSignedFixture(t)
+	v.Require = true
+	//a signed answer of another name
+	z.replay["subfwd-x.example.com."] = "subfwd-a.example.com."
+	if txts, err := v.LookupTXT("subfwd-x.example.com"); err == nil {
+		t.Errorf("expected the replayed answer to be ignored, got %v", txts)
+	}
+	//signed records of another name alongside the answer
+	z.recs["subfwd-y.example.com."] = []dnsmessage.Resource{cnameRR("subfwd-y.example.com.", "subfwd-b.example.com.")}
+	z.replay["subfwd-b.example.com."] = "subfwd-a.example.com."
+	if txts, err := v.LookupTXT("subfwd-y.example.com"); err == nil {
+		t.Errorf("expected the replayed answer to be ignored, got %v", txts)
+	}
+}
+
+func TestValidatorRequire(t *testing.T) {
+	_, v := newSignedFixture(t)
+	v.Domains = []string{"insecure.com"}
+	if txts, err := v.LookupTXT("subfwd-u.insecure.com"); err == nil {
+		t.Errorf("expected an unsigned error, got %v", txts)
+	}
+	if txts, err := v.LookupTXT("subfwd-a.example.com"); err != nil || txts[0] != "http://signed.com" {
+		t.Errorf("got %v %v", txts, err)
+	}
+	v.Domains = nil
+	v.Require = true
+	if txts, err := v.LookupTXT("subfwd-d.example.com"); err == nil {
+		t.Errorf("expected an unsigned error, got %v", txts)
+	}
+}
+
+func TestValidatorServer(t *testing.T) {
+	z, _ := newSignedFixture(t)
+	z.strip["subfwd-b.example.com."] = true
+	s, err := New(Config{Resolver: z, DNSSEC: "validate", Anchors: []string{z.anchor("com.")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := do(s, "GET", "http://a.example.com/")
+	if w.Code != 302 || w.Header().Get("Location") != "http://signed.com" {
+		t.Errorf("got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	w = do(s, "GET", "http://b.example.com/")
+	if w.Code != 502 || w.Body.String() != "Redirect failed [DNSSEC validation failed]" {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,123 @@
+package subfwd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const dnsMessageType = "application/dns-message"
+
+//DoHResolver sends queries to a DNS-over-HTTPS
+//endpoint using the RFC 8484 wire format, via
+//either GET (the default) or POST requests
+type DoHResolver struct {
+	URL    string
+	Method string
+	Client *http.Client
+}
+
+//NewDoHResolver creates a resolver which queries
+//the DNS-over-HTTPS endpoint at the given URL
+func NewDoHResolver(url string) *DoHResolver {
+	return &DoHResolver{
+		URL:    url,
+		Method: "GET",
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+//LookupTXT returns the TXT records of the given name
+func (d *DoHResolver) LookupTXT(name string) ([]string, error) {
+	txts, _, err := d.LookupTXTTTL(name)
+	return txts, err
+}
+
+//LookupTXTTTL returns the TXT records of the given
+//name along with their smallest time-to-live
+func (d *DoHResolver) LookupTXTTTL(name string) ([]string, time.Duration, error) {
+	msg, err := d.exchange(name, dnsmessage.TypeTXT, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	return txtAnswers(msg, name, d.URL)
+}
+
+//LookupURI returns the URI records of the given
+//name along with their smallest time-to-live
+func (d *DoHResolver) LookupURI(name string) ([]*URI, time.Duration, error) {
+	msg, err := d.exchange(name, typeURI, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	return uriAnswers(msg, name, d.URL)
+}
+
+//LookupCNAME returns the canonical name of the given name
+func (d *DoHResolver) LookupCNAME(name string) (string, error) {
+	msg, err := d.exchange(name, dnsmessage.TypeCNAME, false)
+	if err != nil {
+		return "", err
+	}
+	return cnameAnswer(msg, name, d.URL)
+}
+
+func (d *DoHResolver) exchange(name string, qtype dnsmessage.Type, dnssec bool) (*dnsmessage.Message, error) {
+	msg, err := d.roundTrip(name, qtype, dnssec)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: d.URL, IsTimeout: isTimeout(err)}
+	}
+	return checkRCode(msg, name, d.URL)
+}
+
+func (d *DoHResolver) roundTrip(name string, qtype dnsmessage.Type, dnssec bool) (*dnsmessage.Message, error) {
+	//use a zero ID to make GET responses cache friendly
+	query, err := newQuery(name, qtype, 0, dnssec)
+	if err != nil {
+		return nil, err
+	}
+	var req *http.Request
+	if d.Method == "POST" {
+		req, err = http.NewRequest("POST", d.URL, bytes.NewReader(query))
+		if err == nil {
+			req.Header.Set("Content-Type", dnsMessageType)
+		}
+	} else {
+		req, err = http.NewRequest("GET", d.URL, nil)
+		if err == nil {
+			q := req.URL.Query()
+			q.Set("dns", base64.RawURLEncoding.EncodeToString(query))
+			req.URL.RawQuery = q.Encode()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", dnsMessageType)
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server responded with %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != dnsMessageType {
+		return nil, fmt.Errorf("DoH server responded with content type '%s'", ct)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, err
+	}
+	return checkResponse(query, b)
+}
@@ -0,0 +1,119 @@
+package subfwd
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+//startDoH serves the handler as a DNS-over-HTTPS endpoint
+func startDoH(t *testing.T, h dnsHandler) (*DoHResolver, *httptest.Server) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b []byte
+		var err error
+		if r.Method == "POST" {
+			if r.Header.Get("Content-Type") != dnsMessageType {
+				w.WriteHeader(415)
+				return
+			}
+			b, err = io.ReadAll(r.Body)
+		} else {
+			b, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		}
+		q := &dnsmessage.Message{}
+		if err != nil || q.Unpack(b) != nil || r.Header.Get("Accept") != dnsMessageType {
+			w.WriteHeader(400)
+			return
+		}
+		switch q.Questions[0].Name.String() {
+		case "status.example.com.":
+			w.WriteHeader(503)
+			return
+		case "type.example.com.":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+			return
+		}
+		out, _ := h(q, "https")[0].Pack()
+		w.Header().Set("Content-Type", dnsMessageType)
+		w.Write(out)
+	}))
+	t.Cleanup(srv.Close)
+	d := NewDoHResolver(srv.URL + "/dns-query")
+	d.Client = srv.Client()
+	d.Client.Timeout = 5 * time.Second
+	return d, srv
+}
+
+func TestDoHResolver(t *testing.T) {
+	d, _ := startDoH(t, func(q *dnsmessage.Message, network string) []*dnsmessage.Message {
+		name := q.Questions[0].Name.String()
+		switch name {
+		case "subfwd-a.example.com.":
+			return []*dnsmessage.Message{reply(q, txtRR(name, 42, "http://a.com"), txtRR(name, 300, "v=spf1"))}
+		case "www.example.com.":
+			return []*dnsmessage.Message{reply(q, cnameRR(name, "subfwd.herokuapp.com."))}
+		case "other.example.com.":
+			//an answer to another question
+			m := reply(q, txtRR("subfwd-a.example.com.", 42, "http://evil.com"))
+			m.Questions[0].Name = dnsmessage.MustNewName("subfwd-a.example.com.")
+			return []*dnsmessage.Message{m}
+		}
+		m := reply(q)
+		m.RCode = dnsmessage.RCodeNameError
+		return []*dnsmessage.Message{m}
+	})
+	for _, method := range []string{"GET", "POST"} {
+		d.Method = method
+		txts, ttl, err := d.LookupTXTTTL("subfwd-a.example.com")
+		if err != nil {
+			t.Fatalf("%s: %s", method, err)
+		}
+		if strings.Join(txts, ",") != "http://a.com,v=spf1" || ttl != 42*time.Second {
+			t.Errorf("%s: got %v %s", method, txts, ttl)
+		}
+		if cname, err := d.LookupCNAME("www.example.com"); err != nil || cname != "subfwd.herokuapp.com." {
+			t.Errorf("%s: got cname %q %v", method, cname, err)
+		}
+		if _, err := d.LookupTXT("subfwd-b.example.com"); !isNotFound(err) {
+			t.Errorf("%s: expected not found, got %v", method, err)
+		}
+		if _, err := d.LookupTXT("status.example.com"); err == nil || !strings.Contains(err.Error(), "503") {
+			t.Errorf("%s: expected a status error, got %v", method, err)
+		}
+		if _, err := d.LookupTXT("type.example.com"); err == nil || !strings.Contains(err.Error(), "text/html") {
+			t.Errorf("%s: expected a content type error, got %v", method, err)
+		}
+		if txts, err := d.LookupTXT("other.example.com"); err == nil {
+			t.Errorf("%s: expected a mismatched question error, got %v", method, txts)
+		}
+	}
+}
+
+func TestDoHServer(t *testing.T) {
+	d, srv := startDoH(t, func(q *dnsmessage.Message, network string) []*dnsmessage.Message {
+		name := q.Questions[0].Name.String()
+		if name != "subfwd-a.example.com." {
+			m := reply(q)
+			m.RCode = dnsmessage.RCodeNameError
+			return []*dnsmessage.Message{m}
+		}
+		return []*dnsmessage.Message{reply(q, txtRR(name, 60, "http://doh.com/"))}
+	})
+	s, _ := newTestServer(t, Config{Resolver: d})
+	w := do(s, "GET", "http://a.example.com/")
+	if w.Code != 302 || w.Header().Get("Location") != "http://doh.com/" {
+		t.Errorf("got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	//the resolver is selected by its URL
+	r, err := NewResolver(srv.URL)
+	if _, ok := r.(*DoHResolver); err != nil || !ok {
+		t.Errorf("expected a DoH resolver, got %T %v", r, err)
+	}
+}
@@ -0,0 +1,467 @@
+package subfwd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Expr is a compiled condition, evaluated against a request.
+//Expressions are safe to run on untrusted input: they have no
+//loops or side effects and regular expressions run in linear
+//time. Every value is a string, which is true when non-empty.
+//
+//Values:
+//
+//	ua, lang, ip, host, path, method   request properties (lang is
+//	                                   the preferred language, e.g. "en")
+//	mobile                             "true" for mobile user agents
+//	country, continent                 location of the client IP (e.g.
+//	                                   "AU" and "OC"), requires a GeoDB
+//	header('Name'), query('name'),     request header, query
+//	cookie('name')                     parameter and cookie values
+//	'text' or "text"                   string literals, \', \" and \\
+//	                                   are escapes, other backslashes
+//	                                   are kept (e.g. 'Chrome/\d+')
+//
+//Operators, in increasing order of precedence:
+//
+//	a || b, a && b, !a
+//	a == 'x', a != 'x'
+//	a ~ 'regexp', a !~ 'regexp'
+//	a in ['x', 'y']   (CIDR items match IPs, e.g. ip in '10.0.0.0/8')
+//
+//For example:
+//
+//	mobile && ua ~ 'iPhone|iPad' || query('app') == 'ios'
+type Expr struct {
+	Source string
+	eval   exprFunc
+}
+
+//exprFunc evaluates (part of) an expression
+type exprFunc func(env *exprEnv) string
+
+//exprEnv is the request an expression is evaluated against
+type exprEnv struct {
+	r         *http.Request
+	ip        string
+	geo       *GeoDB
+	located   bool
+	country   string
+	continent string
+}
+
+//locate looks up the location of the client IP once
+func (env *exprEnv) locate() {
+	if !env.located {
+		env.country, env.continent = env.geo.Lookup(net.ParseIP(env.ip))
+		env.located = true
+	}
+}
+
+//maxExprLen bounds the source of an expression
+const maxExprLen = 1024
+
+//maxExprCache bounds the compile cache, which
+//is reset once full (records rarely change)
+const maxExprCache = 1024
+
+var exprCache = struct {
+	sync.Mutex
+	m map[string]*Expr
+}{m: map[string]*Expr{}}
+
+var mobileAgents = regexp.MustCompile(`(?i)mobile|android|iphone|ipad|ipod|windows phone`)
+
+//CompileExpr compiles the given expression. Since rules are
+//parsed on every lookup, compiled expressions are cached.
+func CompileExpr(src string) (*Expr, error) {
+	exprCache.Lock()
+	e, ok := exprCache.m[src]
+	exprCache.Unlock()
+	if ok {
+		return e, nil
+	}
+	if len(src) > maxExprLen {
+		return nil, fmt.Errorf("expression longer than %d bytes", maxExprLen)
+	}
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	fn, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "" {
+		return nil, fmt.Errorf("unexpected '%s'", p.peek())
+	}
+	e = &Expr{Source: src, eval: fn}
+	exprCache.Lock()
+	if len(exprCache.m) >= maxExprCache {
+		exprCache.m = map[string]*Expr{}
+	}
+	exprCache.m[src] = e
+	exprCache.Unlock()
+	return e, nil
+}
+
+//Match evaluates the expression against the given request,
+//the geo database is optional (locations are then empty)
+func (e *Expr) Match(r *http.Request, geo *GeoDB) bool {
+	return e.eval(&exprEnv{r: r, ip: clientIP(r), geo: geo}) != ""
+}
+
+//lexExpr splits an expression into tokens,
+//string literals keep their opening quote
+func lexExpr(src string) ([]string, error) {
+	toks := []string{}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\'' || c == '"':
+			s := []byte{c}
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) && strings.IndexByte(`'"\\`, src[j+1]) != -1 {
+					j++
+				}
+				s = append(s, src[j])
+			}
+			if j == len(src) {
+				return nil, errors.New("unterminated string")
+			}
+			toks = append(toks, string(s))
+			i = j + 1
+		case isIdent(c):
+			j := i
+			for j < len(src) && isIdent(src[j]) {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"==", "!=", "!~", "&&", "||", "~", "!", "(", ")", "[", "]", ","} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected '%c'", c)
+			}
+			toks = append(toks, op)
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isString(tok string) bool {
+	return tok != "" && (tok[0] == '\'' || tok[0] == '"')
+}
+
+//exprParser is a recursive descent parser,
+//which compiles as it goes
+type exprParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *exprParser) expect(tok string) error {
+	if t := p.next(); t != tok {
+		return fmt.Errorf("expected '%s', found '%s'", tok, t)
+	}
+	return nil
+}
+
+func (p *exprParser) or() (exprFunc, error) {
+	a, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		b, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		a = func(a, b exprFunc) exprFunc {
+			return func(env *exprEnv) string {
+				if v := a(env); v != "" {
+					return v
+				}
+				return b(env)
+			}
+		}(a, b)
+	}
+	return a, nil
+}
+
+func (p *exprParser) and() (exprFunc, error) {
+	a, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		b, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		a = func(a, b exprFunc) exprFunc {
+			return func(env *exprEnv) string {
+				if a(env) == "" {
+					return ""
+				}
+				return b(env)
+			}
+		}(a, b)
+	}
+	return a, nil
+}
+
+func (p *exprParser) unary() (exprFunc, error) {
+	if p.peek() != "!" {
+		return p.compare()
+	}
+	p.next()
+	a, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(env *exprEnv) string {
+		return boolString(a(env) == "")
+	}, nil
+}
+
+func (p *exprParser) compare() (exprFunc, error) {
+	a, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case "==", "!=":
+		p.next()
+		b, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		eq := op == "=="
+		return func(env *exprEnv) string {
+			return boolString((a(env) == b(env)) == eq)
+		}, nil
+	case "~", "!~":
+		p.next()
+		lit := p.next()
+		if !isString(lit) {
+			return nil, fmt.Errorf("expected a regexp string after '%s'", op)
+		}
+		re, err := regexp.Compile(lit[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp '%s'", lit[1:])
+		}
+		match := op == "~"
+		return func(env *exprEnv) string {
+			return boolString(re.MatchString(a(env)) == match)
+		}, nil
+	case "in":
+		p.next()
+		items, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		nets := []*net.IPNet{}
+		strs := map[string]bool{}
+		for _, item := range items {
+			if _, n, err := net.ParseCIDR(item); err == nil {
+				nets = append(nets, n)
+			} else {
+				strs[item] = true
+			}
+		}
+		return func(env *exprEnv) string {
+			v := a(env)
+			if strs[v] {
+				return "true"
+			}
+			if ip := net.ParseIP(v); ip != nil {
+				for _, n := range nets {
+					if n.Contains(ip) {
+						return "true"
+					}
+				}
+			}
+			return ""
+		}, nil
+	}
+	return a, nil
+}
+
+//list parses a string literal or a list of them
+func (p *exprParser) list() ([]string, error) {
+	if isString(p.peek()) {
+		return []string{p.next()[1:]}, nil
+	}
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	items := []string{}
+	for {
+		lit := p.next()
+		if !isString(lit) {
+			return nil, fmt.Errorf("expected a string, found '%s'", lit)
+		}
+		items = append(items, lit[1:])
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	return items, p.expect("]")
+}
+
+func (p *exprParser) value() (exprFunc, error) {
+	tok := p.next()
+	if isString(tok) {
+		lit := tok[1:]
+		return func(*exprEnv) string { return lit }, nil
+	}
+	switch tok {
+	case "(":
+		a, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return a, p.expect(")")
+	case "ua":
+		return func(env *exprEnv) string { return env.r.UserAgent() }, nil
+	case "lang":
+		return func(env *exprEnv) string { return preferredLanguage(env.r.Header.Get("Accept-Language")) }, nil
+	case "ip":
+		return func(env *exprEnv) string { return env.ip }, nil
+	case "host":
+		return func(env *exprEnv) string { return env.r.Host }, nil
+	case "path":
+		return func(env *exprEnv) string { return env.r.URL.Path }, nil
+	case "method":
+		return func(env *exprEnv) string { return env.r.Method }, nil
+	case "country":
+		return func(env *exprEnv) string { env.locate(); return env.country }, nil
+	case "continent":
+		return func(env *exprEnv) string { env.locate(); return env.continent }, nil
+	case "mobile":
+		return func(env *exprEnv) string { return boolString(mobileAgents.MatchString(env.r.UserAgent())) }, nil
+	case "header", "query", "cookie":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		lit := p.next()
+		if !isString(lit) {
+			return nil, fmt.Errorf("expected a name string in %s()", tok)
+		}
+		name := lit[1:]
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		switch tok {
+		case "header":
+			return func(env *exprEnv) string { return env.r.Header.Get(name) }, nil
+		case "query":
+			return func(env *exprEnv) string { return env.r.URL.Query().Get(name) }, nil
+		}
+		return func(env *exprEnv) string {
+			if c, err := env.r.Cookie(name); err == nil {
+				return c.Value
+			}
+			return ""
+		}, nil
+	case "":
+		return nil, errors.New("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unknown value '%s'", tok)
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return ""
+}
+
+//preferredLanguage returns the primary subtag of the highest
+//weighted language of an Accept-Language header, e.g. "en"
+func preferredLanguage(header string) string {
+	best, bestQ := "", -1.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if tag != "" && tag != "*" && q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return strings.ToLower(strings.SplitN(best, "-", 2)[0])
+}
+
+//Condition is a conditional target within a Rule, written
+//as "when=<expression> => <url>" (see Expr). Conditions are
+//evaluated in order, the first to match selects its URL.
+type Condition struct {
+	Expr string
+	URL  string
+	expr *Expr
+}
+
+//parseCondition parses "<expression> => <url>"
+func parseCondition(s string) (*Condition, error) {
+	i := strings.LastIndex(s, "=>")
+	if i == -1 {
+		return nil, fmt.Errorf("invalid condition '%s'", s)
+	}
+	c := &Condition{Expr: strings.TrimSpace(s[:i]), URL: strings.TrimSpace(s[i+2:])}
+	if err := checkURL(c.URL); err != nil {
+		return nil, err
+	}
+	e, err := CompileExpr(c.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition '%s': %s", c.Expr, err)
+	}
+	c.expr = e
+	return c, nil
+}
@@ -0,0 +1,132 @@
+package subfwd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	r1 := httptest.NewRequest("GET", "http://a.com/x?app=ios", nil)
+	r1.Header.Set("User-Agent", "Mozilla (iPhone; Mobile)")
+	r1.Header.Set("Accept-Language", "en-US;q=0.5, de-DE, fr;q=0.9")
+	r1.RemoteAddr = "10.2.3.4:55"
+	r1.AddCookie(&http.Cookie{Name: "c", Value: "1"})
+	r2 := httptest.NewRequest("POST", "http://a.com/y", nil)
+	r2.Header.Set("X-A", "b")
+	r2.Header.Set("User-Agent", "Chrome/120 Safari")
+	r2.RemoteAddr = "192.168.1.1:5"
+	for _, c := range []struct {
+		src    string
+		r1, r2 bool
+	}{
+		{`mobile && ua ~ 'iPhone|iPad' || query('app') == 'ios'`, true, false},
+		{`ip in ['10.0.0.0/8', '192.168.1.1']`, true, true},
+		{`lang in ['de','fr']`, true, false},
+		{`!(header('X-A') == "b") && path ~ '^/x'`, true, false},
+		{`cookie('c')`, true, false},
+		{`method != 'GET'`, false, true},
+		//backslashes are kept, except before quotes and backslashes
+		{`ua ~ 'Chrome/\d+'`, false, true},
+		{`ua ~ "Chrome/\d+ \"?Safari"`, false, true},
+		{`header('X-A') == 'b\'' || ua ~ '\(iPhone'`, true, false},
+		{`'a\\' == "a\\"`, true, true},
+	} {
+		e, err := CompileExpr(c.src)
+		if err != nil {
+			t.Errorf("%s: %s", c.src, err)
+			continue
+		}
+		if e.Match(r1, nil) != c.r1 || e.Match(r2, nil) != c.r2 {
+			t.Errorf("%s: got %v %v, want %v %v", c.src, e.Match(r1, nil), e.Match(r2, nil), c.r1, c.r2)
+		}
+	}
+}
+
+func TestLexExpr(t *testing.T) {
+	toks, err := lexExpr(`ua ~ 'a\d\'b\\' && x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(toks) != 5 || toks[2] != `'a\d'b\` {
+		t.Errorf("got %q", toks)
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		`ua ~ '('`: "invalid regexp '('",
+		`foo`:      "unknown value 'foo'",
+		`ua ==`:    "unexpected end of expression",
+		`'a`:       "unterminated string",
+		`'a\'`:     "unterminated string",
+		`ua in x`:  "expected '[', found 'x'",
+		`(ua`:      "expected ')', found ''",
+		`ua @ 'x'`: "unexpected '@'",
+	} {
+		if _, err := CompileExpr(src); err == nil || err.Error() != want {
+			t.Errorf("%s: got %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestConditions(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-app.example.com", `v=subfwd1; url=https://site.com; when="ua ~ 'iPhone' => https://apps.apple.com/x"; when="ua ~ 'Android' => https://play.google.com/x"; when="ip in '10.0.0.0/8' => http://internal/"`)
+	f.SetTXT("subfwd-bad.example.com", `v=subfwd1; url=https://site.com; when="ua ~ '(' => https://x.com"`)
+	for ua, want := range map[string]string{
+		"iPhone":  "https://apps.apple.com/x",
+		"Android": "https://play.google.com/x",
+		"Desktop": "https://site.com",
+	} {
+		r := httptest.NewRequest("GET", "http://app.example.com/p", nil)
+		r.Header.Set("User-Agent", ua)
+		w := httptest.NewRecorder()
+		s.route(w, r)
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != want {
+			t.Errorf("%s: got %d %q, want %q", ua, w.Code, loc, want)
+		}
+	}
+	if w := do(s, "GET", "http://bad.example.com/"); w.Code != 404 {
+		t.Errorf("invalid condition: got %d", w.Code)
+	}
+}
+
+func TestTestEndpoint(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-app.example.com", `v=subfwd1; url=https://site.com; when="ua ~ 'Android' => https://play.google.com/x"; when="ip in '10.0.0.0/8' => http://internal/"`)
+	f.SetTXT("subfwd-bad.example.com", `v=subfwd1; url=https://site.com; when="ua ~ '(' => https://x.com"`)
+	type out struct {
+		Forward *forward
+		Error   string
+	}
+	for _, c := range []struct {
+		query, target, cond, err string
+	}{
+		{"host=app.example.com&ua=Android", "https://play.google.com/x", "ua ~ 'Android'", ""},
+		{"host=app.example.com&ip=10.1.1.1", "http://internal/", "ip in '10.0.0.0/8'", ""},
+		{"host=app.example.com", "https://site.com", "", ""},
+		{"host=bad.example.com", "", "", "No TXT"},
+		//rules can be tested without records
+		{"url=http://z.com/a/b%3Fc&lang=fr&rule=" + url.QueryEscape(`v=subfwd1; url=https://z.com; mode=proxy; when="lang == 'fr' => https://fr.z.com"`), "https://fr.z.com/a/b?c=", "lang == 'fr'", ""},
+		{"url=http://z.com/&time=2031-01-01&rule=" + url.QueryEscape(`v=subfwd1; url=https://z.com; not_after=2030-01-01`), "", "", "Expired"},
+	} {
+		w := do(s, "GET", "http://subfwd.jpillora.com/test?"+c.query)
+		o := &out{}
+		if err := json.Unmarshal(w.Body.Bytes(), o); err != nil {
+			t.Fatalf("%s: %s %s", c.query, err, w.Body.String())
+		}
+		target, cond := "", ""
+		if o.Forward != nil {
+			target, cond = o.Forward.Target, o.Forward.Condition
+		}
+		if target != c.target || cond != c.cond || o.Error != c.err {
+			t.Errorf("%s: got %q %q %q", c.query, target, cond, o.Error)
+		}
+	}
+	if w := do(s, "GET", "http://subfwd.jpillora.com/test"); w.Code != 400 {
+		t.Errorf("got %d", w.Code)
+	}
+}
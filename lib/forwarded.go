@@ -0,0 +1,201 @@
+package subfwd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+//forwardedHeaders are the headers describing the client
+//and the original request, set by proxies in front of subfwd
+var forwardedHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "X-Real-IP"}
+
+//forwardedPolicies are the sets of forwarded headers sent
+//to proxied targets: X-Forwarded-For only (xff), along with
+//X-Forwarded-Host and X-Forwarded-Proto (x-forwarded), the
+//RFC 7239 Forwarded header (rfc7239), all of them or none
+var forwardedPolicies = map[string]bool{"xff": true, "x-forwarded": true, "rfc7239": true, "all": true, "none": true}
+
+//clientIPKey holds the client IP of a trusted request
+type clientIPKey struct{}
+
+//parseCIDRs parses the given CIDRs, plain IPs are single addresses
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, c := range cidrs {
+		cidr := c
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s'", c)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+//herokuRouter is the private network the Heroku router connects from
+const herokuRouter = "10.0.0.0/8"
+
+//loopbackOnly returns whether no proxies, other than local ones, are trusted
+func loopbackOnly(nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if !n.IP.IsLoopback() {
+			return false
+		}
+	}
+	return true
+}
+
+//trusted returns whether the given IP is a trusted proxy
+func (s *Subfwd) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range s.trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+//trust returns the request along with its client IP. The
+//forwarded headers of trusted proxies are kept, and the client
+//is the last untrusted address of their chain, the forwarded
+//headers of other requests are removed.
+func (s *Subfwd) trust(r *http.Request) *http.Request {
+	ip := remoteIP(r)
+	if !s.trusted(ip) {
+		for _, h := range forwardedHeaders {
+			r.Header.Del(h)
+		}
+	} else if chain := forwardedChain(r.Header); len(chain) > 0 {
+		for i := len(chain) - 1; i >= 0; i-- {
+			ip = chain[i]
+			if !s.trusted(ip) {
+				break
+			}
+		}
+	} else if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		ip = realIP
+	}
+	return r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+}
+
+//clientIP returns the IP of the client (see trust),
+//otherwise the remote address of the request
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+//remoteIP returns the IP of the remote address,
+//without the port or the brackets of IPv6 addresses
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return strings.Trim(r.RemoteAddr, "[]")
+}
+
+//forwardedChain returns the addresses of the X-Forwarded-For
+//header, or otherwise the "for" addresses of the Forwarded header
+func forwardedChain(h http.Header) []string {
+	chain := []string{}
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(v, ",") {
+			chain = append(chain, forwardedIP(ip))
+		}
+	}
+	if len(chain) > 0 {
+		return chain
+	}
+	for _, v := range h.Values("Forwarded") {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					chain = append(chain, forwardedIP(kv[1]))
+				}
+			}
+		}
+	}
+	return chain
+}
+
+//forwardedIP returns the IP of a forwarded address, which
+//may be quoted and may have a port (e.g. "[2001:db8::1]:80")
+func forwardedIP(addr string) string {
+	addr = strings.Trim(strings.TrimSpace(addr), `"`)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+//setForwarded sets the forwarded headers of an outgoing proxy
+//request with the given policy. The headers of trusted proxies
+//are kept (see trust), and describe the original request.
+//X-Forwarded-For is appended to by the reverse proxy itself.
+func setForwarded(req *http.Request, policy, host string) {
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	switch policy {
+	case "xff", "x-forwarded", "all":
+	default:
+		//a nil value stops the reverse proxy adding it
+		req.Header["X-Forwarded-For"] = nil
+		req.Header.Del("X-Real-IP")
+	}
+	switch policy {
+	case "x-forwarded", "all":
+		if req.Header.Get("X-Forwarded-Host") == "" {
+			req.Header.Set("X-Forwarded-Host", host)
+		}
+		if req.Header.Get("X-Forwarded-Proto") == "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+	default:
+		req.Header.Del("X-Forwarded-Host")
+		req.Header.Del("X-Forwarded-Proto")
+	}
+	switch policy {
+	case "rfc7239", "all":
+		ip := remoteIP(req)
+		if strings.Contains(ip, ":") {
+			ip = "[" + ip + "]"
+		}
+		elem := "for=" + forwardedValue(ip) + ";host=" + forwardedValue(host) + ";proto=" + proto
+		if prior := req.Header.Values("Forwarded"); len(prior) > 0 {
+			elem = strings.Join(prior, ", ") + ", " + elem
+		}
+		req.Header.Set("Forwarded", elem)
+	default:
+		req.Header.Del("Forwarded")
+	}
+}
+
+//forwardedValue quotes a Forwarded parameter value
+//unless it only contains token characters
+func forwardedValue(v string) string {
+	for _, c := range v {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+		}
+	}
+	return v
+}
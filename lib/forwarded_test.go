@@ -0,0 +1,130 @@
+package subfwd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestForwarded(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "xff=%q xfh=%q xfp=%q fwd=%q", r.Header.Get("X-Forwarded-For"), r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Forwarded-Proto"), r.Header.Get("Forwarded"))
+	}))
+	defer backend.Close()
+	//the headers sent by an untrusted client, and by a
+	//trusted proxy, which forwards for a trusted proxy
+	untrusted := []string{"1.2.3.4:5", "6.6.6.6"}
+	proxied := []string{"10.1.1.1:5", "6.6.6.6, 192.168.1.1"}
+	for _, c := range []struct {
+		policy string
+		sent   []string
+		want   string
+	}{
+		{"", untrusted, `xff="1.2.3.4" xfh="p.example.com" xfp="http" fwd=""`},
+		{"xff", untrusted, `xff="1.2.3.4" xfh="" xfp="" fwd=""`},
+		{"xff", proxied, `xff="6.6.6.6, 192.168.1.1, 10.1.1.1" xfh="" xfp="" fwd=""`},
+		{"x-forwarded", untrusted, `xff="1.2.3.4" xfh="p.example.com" xfp="http" fwd=""`},
+		{"x-forwarded", proxied, `xff="6.6.6.6, 192.168.1.1, 10.1.1.1" xfh="orig.com" xfp="http" fwd=""`},
+		{"rfc7239", untrusted, `xff="" xfh="" xfp="" fwd="for=1.2.3.4;host=p.example.com;proto=http"`},
+		{"rfc7239", proxied, `xff="" xfh="" xfp="" fwd="for=6.6.6.6, for=10.1.1.1;host=p.example.com;proto=http"`},
+		{"all", untrusted, `xff="1.2.3.4" xfh="p.example.com" xfp="http" fwd="for=1.2.3.4;host=p.example.com;proto=http"`},
+		{"none", proxied, `xff="" xfh="" xfp="" fwd=""`},
+	} {
+		s, f := newTestServer(t, Config{Forwarded: c.policy, TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}})
+		f.SetTXT("subproxy-p.example.com", backend.URL+"/")
+		r := httptest.NewRequest("GET", "http://p.example.com/", nil)
+		r.RemoteAddr = c.sent[0]
+		r.Header.Set("X-Forwarded-For", c.sent[1])
+		r.Header.Set("X-Forwarded-Host", "orig.com")
+		r.Header.Set("Forwarded", "for=6.6.6.6")
+		w := httptest.NewRecorder()
+		s.route(w, r)
+		if w.Code != 200 || w.Body.String() != c.want {
+			t.Errorf("%q from %s: got %d %s, want %s", c.policy, c.sent[0], w.Code, w.Body.String(), c.want)
+		}
+	}
+}
+
+func TestForwardedRecords(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "host=%s fwd=%q", r.Host, r.Header.Get("Forwarded"))
+	}))
+	defer backend.Close()
+	s, f := newTestServer(t, Config{Forwarded: "none"})
+	f.SetTXT("subproxy-p.example.com", backend.URL+"/")
+	f.SetTXT("subproxy-k.example.com", "v=subfwd1; url="+backend.URL+"/; host=preserve; forwarded=rfc7239")
+	for _, c := range []struct {
+		remote, host, want string
+	}{
+		{"10.1.1.1:5", "p", `host=` + strings.TrimPrefix(backend.URL, "http://") + ` fwd=""`},
+		{"10.1.1.1:5", "k", `host=k.example.com fwd="for=10.1.1.1;host=k.example.com;proto=http"`},
+		{"[2001:db8::1]:5", "k", `host=k.example.com fwd="for=\"[2001:db8::1]\";host=k.example.com;proto=http"`},
+	} {
+		r := httptest.NewRequest("GET", "http://"+c.host+".example.com/", nil)
+		r.RemoteAddr = c.remote
+		w := httptest.NewRecorder()
+		s.route(w, r)
+		if w.Body.String() != c.want {
+			t.Errorf("%s from %s: got %d %s, want %s", c.host, c.remote, w.Code, w.Body.String(), c.want)
+		}
+	}
+	if _, err := ParseRule("v=subfwd1; url=http://x.com; host=bad"); err == nil {
+		t.Error("expected an invalid host error")
+	}
+}
+
+func TestTrust(t *testing.T) {
+	s, _ := newTestServer(t, Config{TrustedProxies: []string{"127.0.0.0/8", "::1"}})
+	for _, c := range []struct {
+		remote, xff, fwd, real, want string
+	}{
+		{"1.2.3.4:5", "6.6.6.6", "", "", "1.2.3.4"},
+		{"127.0.0.1:5", "6.6.6.6, 127.0.0.2", "", "", "6.6.6.6"},
+		{"[::1]:5", "", `for="[2001:db8::1]:80"`, "", "2001:db8::1"},
+		{"127.0.0.1:5", "", "", "7.7.7.7", "7.7.7.7"},
+		//other private addresses are not trusted
+		{"10.1.1.1:5", "6.6.6.6", "", "", "10.1.1.1"},
+	} {
+		r := httptest.NewRequest("GET", "http://a.example.com/", nil)
+		r.RemoteAddr = c.remote
+		for h, v := range map[string]string{"X-Forwarded-For": c.xff, "Forwarded": c.fwd, "X-Real-IP": c.real} {
+			if v != "" {
+				r.Header.Set(h, v)
+			}
+		}
+		if got := clientIP(s.trust(r)); got != c.want {
+			t.Errorf("%s %q: got %s, want %s", c.remote, c.xff+c.fwd+c.real, got, c.want)
+		}
+	}
+	if _, err := New(Config{Forwarded: "bogus", Resolver: NewFakeResolver()}); err == nil {
+		t.Error("expected an invalid policy error")
+	}
+	if _, err := New(Config{TrustedProxies: []string{"nope"}, Resolver: NewFakeResolver()}); err == nil || err.Error() != "invalid trusted proxy 'nope'" {
+		t.Errorf("expected an invalid trusted proxy error, got %v", err)
+	}
+}
+
+func TestTrustHeroku(t *testing.T) {
+	defaults := []string{"127.0.0.0/8", "::1/128"}
+	for _, c := range []struct {
+		dyno, want string
+	}{
+		{"", "10.1.1.1"},
+		//the router's forwarded headers are kept on Heroku
+		{"web.1", "6.6.6.6"},
+	} {
+		t.Setenv("DYNO", c.dyno)
+		s, _ := newTestServer(t, Config{TrustedProxies: defaults})
+		r := httptest.NewRequest("GET", "http://a.example.com/", nil)
+		r.RemoteAddr = "10.1.1.1:5"
+		r.Header.Set("X-Forwarded-For", "6.6.6.6")
+		if got := clientIP(s.trust(r)); got != c.want {
+			t.Errorf("dyno %q: got %s, want %s", c.dyno, got, c.want)
+		}
+	}
+	if len(defaults) != 2 {
+		t.Errorf("got %v, expected the configured proxies to be unchanged", defaults)
+	}
+}
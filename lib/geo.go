@@ -0,0 +1,304 @@
+package subfwd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+//geoPollInterval is how often the database
+//file is checked for changes
+const geoPollInterval = 30 * time.Second
+
+//mmdbMarker precedes the metadata of an mmdb file
+var mmdbMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+//GeoDB is a MaxMind format (mmdb) IP database, such as
+//GeoLite2-Country, GeoIP2-City or DB-IP. The database is
+//loaded into memory and reloaded when its file changes.
+type GeoDB struct {
+	Path string
+	Logf func(string, ...interface{})
+	file *fileWatcher
+	mut  sync.RWMutex
+	db   *mmdb
+}
+
+//OpenGeoDB loads the given database file and
+//watches it for changes in the background
+func OpenGeoDB(path string) (*GeoDB, error) {
+	g := &GeoDB{Path: path, Logf: func(string, ...interface{}) {}}
+	g.file = &fileWatcher{path: path, interval: geoPollInterval, reload: g.reload}
+	if err := g.file.load(false); err != nil {
+		return nil, err
+	}
+	go g.file.watch(nil, func(err error) {
+		g.Logf("Geo database reload failed: %s", err)
+	})
+	return g, nil
+}
+
+//Lookup returns the ISO country code (e.g. "AU") and
+//continent code (e.g. "OC") of the given IP, if known
+func (g *GeoDB) Lookup(ip net.IP) (string, string) {
+	if g == nil || ip == nil {
+		return "", ""
+	}
+	g.mut.RLock()
+	db := g.db
+	g.mut.RUnlock()
+	v, err := db.lookup(ip)
+	if err != nil || v == nil {
+		return "", ""
+	}
+	return mmdbString(v, "country", "iso_code"), mmdbString(v, "continent", "code")
+}
+
+//reload replaces the database with the given file
+func (g *GeoDB) reload(b []byte) error {
+	db, err := parseMMDB(b)
+	if err != nil {
+		return fmt.Errorf("invalid geo database %s: %s", g.Path, err)
+	}
+	g.mut.Lock()
+	reload := g.db != nil
+	g.db = db
+	g.mut.Unlock()
+	if reload {
+		g.Logf("Reloaded geo database %s (%s)", g.Path, db.kind)
+	}
+	return nil
+}
+
+//mmdb is a parsed MaxMind DB, see
+//http://maxmind.github.io/MaxMind-DB/
+type mmdb struct {
+	kind       string
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	tree       []byte
+	data       []byte
+	ipv4Start  uint
+}
+
+func parseMMDB(b []byte) (*mmdb, error) {
+	i := bytes.LastIndex(b, mmdbMarker)
+	if i == -1 {
+		return nil, errors.New("metadata not found")
+	}
+	meta, _, err := (&mmdbDecoder{b[i+len(mmdbMarker):]}).decode(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	db := &mmdb{
+		kind:       mmdbString(meta, "database_type"),
+		nodeCount:  mmdbUint(meta, "node_count"),
+		recordSize: mmdbUint(meta, "record_size"),
+		ipVersion:  mmdbUint(meta, "ip_version"),
+	}
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", db.recordSize)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+16 > uint(i) {
+		return nil, errors.New("search tree is truncated")
+	}
+	db.tree = b[:treeSize]
+	db.data = b[treeSize+16 : i]
+	//IPv4 addresses are found under ::/96 of IPv6 databases
+	if db.ipVersion == 6 {
+		for n := 0; n < 96 && db.ipv4Start < db.nodeCount; n++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+	return db, nil
+}
+
+//lookup returns the data record of the given IP
+func (db *mmdb) lookup(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		node = db.ipv4Start
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+	for i := 0; i < len(ip)*8 && node < db.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		node = db.record(node, bit)
+	}
+	if node <= db.nodeCount {
+		return nil, nil //not found
+	}
+	offset := node - db.nodeCount - 16
+	v, _, err := (&mmdbDecoder{db.data}).decode(offset, 0)
+	return v, err
+}
+
+//record returns the left (0) or right (1) record of a node
+func (db *mmdb) record(node, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		b := db.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	}
+	return uint(binary.BigEndian.Uint32(db.tree[node*8+bit*4:]))
+}
+
+//mmdbDecoder decodes the data section format
+type mmdbDecoder struct {
+	b []byte
+}
+
+//maxMMDBDepth bounds the nesting of decoded values
+const maxMMDBDepth = 32
+
+var errMMDBData = errors.New("invalid data section")
+
+//decode returns the value at the given offset,
+//and the offset following it
+func (d *mmdbDecoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxMMDBDepth || offset >= uint(len(d.b)) {
+		return nil, 0, errMMDBData
+	}
+	ctrl := d.b[offset]
+	offset++
+	kind := ctrl >> 5
+	if kind == 1 {
+		//pointer
+		n := uint(ctrl>>3&3) + 1
+		if offset+n > uint(len(d.b)) {
+			return nil, 0, errMMDBData
+		}
+		p := uint(0)
+		if n < 4 {
+			p = uint(ctrl & 7)
+		}
+		for _, c := range d.b[offset : offset+n] {
+			p = p<<8 | uint(c)
+		}
+		p += [...]uint{0, 2048, 526336, 0}[n-1]
+		v, _, err := d.decode(p, depth+1)
+		return v, offset + n, err
+	}
+	if kind == 0 {
+		if offset >= uint(len(d.b)) {
+			return nil, 0, errMMDBData
+		}
+		kind = 7 + d.b[offset]
+		offset++
+	}
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.b)) {
+			return nil, 0, errMMDBData
+		}
+		ext := uint(0)
+		for _, c := range d.b[offset : offset+n] {
+			ext = ext<<8 | uint(c)
+		}
+		size = [...]uint{29, 285, 65821}[n-1] + ext
+		offset += n
+	}
+	switch kind {
+	case 7: //map
+		m := map[string]interface{}{}
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errMMDBData
+			}
+			m[key], offset, err = d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case 11: //array
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case 14: //boolean
+		return size != 0, offset, nil
+	}
+	if offset+size > uint(len(d.b)) {
+		return nil, 0, errMMDBData
+	}
+	b := d.b[offset : offset+size]
+	offset += size
+	switch kind {
+	case 2: //string
+		return string(b), offset, nil
+	case 3: //double
+		if size != 8 {
+			return nil, 0, errMMDBData
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case 15: //float
+		if size != 4 {
+			return nil, 0, errMMDBData
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case 5, 6, 8, 9: //unsigned and signed integers
+		if size > 8 {
+			return nil, 0, errMMDBData
+		}
+		u := uint64(0)
+		for _, c := range b {
+			u = u<<8 | uint64(c)
+		}
+		if kind == 8 {
+			return int64(int32(u)), offset, nil
+		}
+		return u, offset, nil
+	case 4, 10: //bytes and uint128
+		return b, offset, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", kind)
+}
+
+//mmdbString returns the string at the given path of a decoded map
+func mmdbString(v interface{}, path ...string) string {
+	for _, k := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		v = m[k]
+	}
+	s, _ := v.(string)
+	return s
+}
+
+//mmdbUint returns the unsigned integer of the given key of a decoded map
+func mmdbUint(v interface{}, key string) uint {
+	m, _ := v.(map[string]interface{})
+	u, _ := m[key].(uint64)
+	return uint(u)
+}
@@ -0,0 +1,218 @@
+package subfwd
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//mmdbValue encodes a value of the MaxMind DB data section
+func mmdbValue(v interface{}) []byte {
+	ctrl := func(kind, size int) []byte {
+		if kind >= 8 {
+			//extended types
+			return []byte{byte(size), byte(kind - 7)}
+		}
+		return []byte{byte(kind<<5 | size)}
+	}
+	switch v := v.(type) {
+	case string:
+		return append(ctrl(2, len(v)), v...)
+	case uint16:
+		return append(ctrl(5, 2), byte(v>>8), byte(v))
+	case uint32:
+		return append(ctrl(6, 4), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case []string:
+		b := ctrl(11, len(v))
+		for _, s := range v {
+			b = append(b, mmdbValue(s)...)
+		}
+		return b
+	case map[string]interface{}:
+		b := ctrl(7, len(v))
+		for k, x := range v {
+			b = append(b, mmdbValue(k)...)
+			b = append(b, mmdbValue(x)...)
+		}
+		return b
+	}
+	panic("unsupported type")
+}
+
+//buildMMDB builds a database mapping the given networks to
+//their country and continent codes, with the given IP version
+//and record size (24, 28 or 32 bits)
+func buildMMDB(v6 bool, recordSize int, nets map[string][2]string) []byte {
+	type pointer struct{ node, bit, offset int }
+	nodes := [][2]int{{-1, -1}}
+	data, pointers := []byte{}, []pointer{}
+	for cidr, loc := range nets {
+		ip, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ones, _ := n.Mask.Size()
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			if v6 {
+				//IPv4 is stored within ::/96
+				ip = append(make(net.IP, 12), ip4...)
+				ones += 96
+			}
+		}
+		cur := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8] >> (7 - uint(i%8)) & 1)
+			if i == ones-1 {
+				pointers = append(pointers, pointer{cur, bit, len(data)})
+				data = append(data, mmdbValue(map[string]interface{}{
+					"country":   map[string]interface{}{"iso_code": loc[0]},
+					"continent": map[string]interface{}{"code": loc[1]},
+				})...)
+				break
+			}
+			if nodes[cur][bit] == -1 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[cur][bit] = len(nodes) - 1
+			}
+			cur = nodes[cur][bit]
+		}
+	}
+	count := len(nodes)
+	for i := range nodes {
+		for bit := range nodes[i] {
+			if nodes[i][bit] == -1 {
+				nodes[i][bit] = count
+			}
+		}
+	}
+	for _, p := range pointers {
+		nodes[p.node][p.bit] = count + 16 + p.offset
+	}
+	b := []byte{}
+	for _, n := range nodes {
+		l, r := uint(n[0]), uint(n[1])
+		switch recordSize {
+		case 24:
+			b = append(b, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			b = append(b, byte(l>>16), byte(l>>8), byte(l), byte(l>>24<<4|r>>24&0xf), byte(r>>16), byte(r>>8), byte(r))
+		case 32:
+			b = append(b, byte(l>>24), byte(l>>16), byte(l>>8), byte(l), byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		}
+	}
+	version := uint16(4)
+	if v6 {
+		version = 6
+	}
+	b = append(b, make([]byte, 16)...)
+	b = append(b, data...)
+	b = append(b, mmdbMarker...)
+	return append(b, mmdbValue(map[string]interface{}{
+		"node_count":    uint32(count),
+		"record_size":   uint16(recordSize),
+		"ip_version":    version,
+		"database_type": "Test-Country",
+		"languages":     []string{"en"},
+	})...)
+}
+
+var testNets = map[string][2]string{
+	"1.2.3.0/24":    {"AU", "OC"},
+	"5.0.0.0/8":     {"DE", "EU"},
+	"2001:db8::/32": {"JP", "AS"},
+}
+
+func TestGeoDB(t *testing.T) {
+	for _, v6 := range []bool{false, true} {
+		for _, size := range []int{24, 28, 32} {
+			db, err := parseMMDB(buildMMDB(v6, size, testNets))
+			if err != nil {
+				t.Fatalf("v6=%v size=%d: %s", v6, size, err)
+			}
+			g := &GeoDB{db: db}
+			v6want := "/"
+			if v6 {
+				v6want = "JP/AS"
+			}
+			for ip, want := range map[string]string{
+				"1.2.3.4":     "AU/OC",
+				"1.2.4.4":     "/",
+				"5.6.7.8":     "DE/EU",
+				"2001:db8::1": v6want,
+				"::1":         "/",
+			} {
+				if c, k := g.Lookup(net.ParseIP(ip)); c+"/"+k != want {
+					t.Errorf("v6=%v size=%d %s: got %s/%s, want %s", v6, size, ip, c, k, want)
+				}
+			}
+		}
+	}
+	if _, err := parseMMDB([]byte("garbage")); err == nil {
+		t.Error("expected an invalid database error")
+	}
+}
+
+func TestGeoConditions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	if err := ioutil.WriteFile(path, buildMMDB(true, 28, testNets), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, f := newTestServer(t, Config{GeoDB: path})
+	f.SetTXT("subfwd-shop.example.com", `v=subfwd1; url=https://shop.com/$COUNTRY/; when="country in ['DE','AT'] => https://shop.de/"; when="continent == 'AS' => https://shop.asia/$CONTINENT"`)
+	for ip, want := range map[string]string{
+		"1.2.3.9":     "https://shop.com/AU/",
+		"5.1.1.1":     "https://shop.de/",
+		"2001:db8::5": "https://shop.asia/AS",
+		"9.9.9.9":     "https://shop.com//",
+	} {
+		r := httptest.NewRequest("GET", "http://shop.example.com/", nil)
+		r.RemoteAddr = net.JoinHostPort(ip, "1")
+		w := httptest.NewRecorder()
+		s.route(w, r)
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != want {
+			t.Errorf("%s: got %d %q, want %q", ip, w.Code, loc, want)
+		}
+	}
+	if _, err := New(Config{GeoDB: path + ".missing", Resolver: f}); err == nil {
+		t.Error("expected a missing database error")
+	}
+}
+
+func TestGeoReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	write := func(b []byte, age time.Duration) {
+		if err := ioutil.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		mod := time.Now().Add(age)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(buildMMDB(false, 24, testNets), -time.Hour)
+	g, err := OpenGeoDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(buildMMDB(false, 24, map[string][2]string{"9.0.0.0/8": {"US", "NA"}}), 0)
+	if err := g.file.load(false); err != nil {
+		t.Fatal(err)
+	}
+	if c, k := g.Lookup(net.ParseIP("9.9.9.9")); c != "US" || k != "NA" {
+		t.Errorf("got %s/%s", c, k)
+	}
+	//invalid databases are not loaded
+	write([]byte("garbage"), time.Hour)
+	if err := g.file.load(false); err == nil || !strings.Contains(err.Error(), "invalid geo database") {
+		t.Errorf("got %v", err)
+	}
+	if c, _ := g.Lookup(net.ParseIP("9.9.9.9")); c != "US" {
+		t.Errorf("got %s", c)
+	}
+}
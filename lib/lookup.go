@@ -0,0 +1,348 @@
+package subfwd
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jpillora/go-tld"
+)
+
+//record is the result of looking up a single TXT
+//record, a set of URI records when Type is "URI",
+//or a host of the rules file when Type is "FILE"
+type record struct {
+	Name   string
+	Type   string
+	Proxy  bool     `json:",omitempty"`
+	Code   int      `json:",omitempty"`
+	TXT    []string `json:",omitempty"`
+	URI    []*URI   `json:",omitempty"`
+	Rule   *Rule    `json:",omitempty"`
+	Errors []string `json:",omitempty"`
+	err    error
+	//deferred records are only looked up
+	//when this record has no rule
+	deferred []*record
+}
+
+//resolution is the result of resolving
+//a request host into a forwarding rule
+type resolution struct {
+	Host      string
+	Domain    string
+	Subdomain string
+	Records   []*record
+	Rule      *Rule `json:",omitempty"`
+	Proxy     bool
+	Code      int `json:",omitempty"`
+}
+
+//redirectCodes have their own record prefix, e.g. subfwd301-
+var redirectCodes = []int{301, 302, 303, 307, 308}
+
+//maxLevels is the maximum number of subdomain
+//levels walked when looking up nested subdomains
+const maxLevels = 4
+
+//resolve looks up the records of the given host and selects
+//its rule. Nested subdomains are walked from the most specific
+//level upwards, and at each level proxy records take precedence
+//over forward records, then the default of the parent level.
+//See /diagnose for the records of a host, in order.
+func (s *Subfwd) resolve(host string) (*resolution, error) {
+	u, err := tld.Parse("http://" + host)
+	if err != nil {
+		return nil, err
+	}
+	//the domain is the host without the port
+	domain := u.Domain + "." + u.TLD
+	res := &resolution{
+		Host:      host,
+		Domain:    domain,
+		Subdomain: domain,
+	}
+	labels := []string{}
+	if u.Subdomain != "" {
+		res.Subdomain = u.Subdomain + "." + domain
+		labels = strings.Split(u.Subdomain, ".")
+	}
+	//each stage is looked up in parallel,
+	//stopping at the first with a rule
+	stages := [][]*record{}
+	var hosts, wildcards []*record
+	if s.rules != nil {
+		hosts, wildcards = s.fileRecords(labels, domain)
+	}
+	if s.rules != nil && s.rulesOrder != "dns" {
+		stages = append(stages, hosts)
+	}
+	naming := s.naming
+	if s.rules != nil && s.rulesOrder == "only" {
+		naming = ""
+	}
+	switch naming {
+	case "legacy":
+		stages = append(stages, s.legacyRecords(labels, domain))
+	case "underscore":
+		levels, defaults := s.underscoreRecords(labels, domain)
+		for i, stage := range levels {
+			stages = append(stages, stage, defaults[i])
+		}
+		stages = append(stages, defaults[len(levels):]...)
+	case "both":
+		//legacy records take precedence over underscore defaults
+		levels, defaults := s.underscoreRecords(labels, domain)
+		stages = append(stages, levels...)
+		stages = append(stages, s.legacyRecords(labels, domain))
+		stages = append(stages, defaults...)
+	}
+	if s.rules != nil && s.rulesOrder == "dns" {
+		stages = append(stages, hosts)
+	}
+	if s.rules != nil {
+		stages = append(stages, wildcards)
+	}
+	for _, stage := range stages {
+		stage = s.lookupStage(stage)
+		res.Records = append(res.Records, stage...)
+		if ruled(stage) {
+			break
+		}
+	}
+	for _, rec := range res.Records {
+		if rec.Rule != nil {
+			res.Rule = rec.Rule
+			res.Proxy = rec.Proxy
+			res.Code = rec.Code
+			break
+		}
+	}
+	if res.Rule != nil {
+		s.settle(res)
+	}
+	return res, nil
+}
+
+//lookupStage looks up the given records in parallel, then
+//the deferred records of those before the first with a
+//rule, which are inserted before the record deferring them
+func (s *Subfwd) lookupStage(stage []*record) []*record {
+	s.lookupRecords(stage)
+	deferred := []*record{}
+	for _, rec := range stage {
+		if rec.Rule != nil {
+			break
+		}
+		deferred = append(deferred, rec.deferred...)
+	}
+	if len(deferred) == 0 {
+		return stage
+	}
+	s.lookupRecords(deferred)
+	recs := []*record{}
+	for i, rec := range stage {
+		if rec.Rule != nil {
+			return append(recs, stage[i:]...)
+		}
+		recs = append(recs, rec.deferred...)
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+//lookupRecords looks up the given records in parallel
+func (s *Subfwd) lookupRecords(recs []*record) {
+	wg := &sync.WaitGroup{}
+	for _, rec := range recs {
+		wg.Add(1)
+		go func(rec *record) {
+			defer wg.Done()
+			s.lookupRecord(rec)
+		}(rec)
+	}
+	wg.Wait()
+}
+
+//settle sets the mode and code of the resolution from its rule
+func (s *Subfwd) settle(res *resolution) {
+	if res.Rule.Mode != "" {
+		res.Proxy = res.Rule.Mode == "proxy"
+	}
+	if res.Rule.Code != 0 {
+		res.Code = res.Rule.Code
+	} else if res.Code == 0 {
+		res.Code = s.redirectCode
+	}
+}
+
+//legacyRecords returns the records of the legacy naming scheme
+func (s *Subfwd) legacyRecords(labels []string, domain string) []*record {
+	recs := []*record{}
+	for i := 0; i < len(labels) && i < maxLevels; i++ {
+		level := strings.Join(labels[i:], ".") + "." + domain
+		parent := strings.Join(append(labels[i+1:], domain), ".")
+		if s.lookupURIs && s.naming == "legacy" {
+			recs = append(recs,
+				&record{Name: "_" + s.proxyPrefix + "." + level, Type: "URI", Proxy: true},
+				&record{Name: "_" + s.prefix + "." + level, Type: "URI"})
+		}
+		plain := &record{Name: s.prefix + "-" + level, Type: "TXT"}
+		for _, code := range redirectCodes {
+			plain.deferred = append(plain.deferred, &record{Name: s.prefix + strconv.Itoa(code) + "-" + level, Type: "TXT", Code: code})
+		}
+		recs = append(recs,
+			&record{Name: s.proxyPrefix + "-" + level, Type: "TXT", Proxy: true},
+			plain,
+			&record{Name: s.prefix + "-default." + parent, Type: "TXT"})
+	}
+	//too deep (or no subdomain), always fallback to the domain default
+	if n := len(recs); n == 0 || recs[n-1].Name != s.prefix+"-default."+domain {
+		recs = append(recs, &record{Name: s.prefix + "-default." + domain, Type: "TXT"})
+	}
+	return recs
+}
+
+//underscoreRecords returns the stages of the underscore naming
+//scheme, the records of each level and the defaults of each parent.
+//URI records share the name of the TXT record of their level.
+func (s *Subfwd) underscoreRecords(labels []string, domain string) (levels, defaults [][]*record) {
+	for i := 0; i < len(labels) && i < maxLevels; i++ {
+		level := strings.Join(labels[i:], ".") + "." + domain
+		parent := strings.Join(append(labels[i+1:], domain), ".")
+		stage := []*record{}
+		if s.lookupURIs {
+			stage = append(stage,
+				&record{Name: "_" + s.proxyPrefix + "." + level, Type: "URI", Proxy: true},
+				&record{Name: "_" + s.prefix + "." + level, Type: "URI"})
+		}
+		levels = append(levels, append(stage, &record{Name: "_" + s.prefix + "." + level, Type: "TXT"}))
+		defaults = append(defaults, []*record{{Name: "_default._" + s.prefix + "." + parent, Type: "TXT"}})
+	}
+	if n := len(defaults); n == 0 || defaults[n-1][0].Name != "_default._"+s.prefix+"."+domain {
+		defaults = append(defaults, []*record{{Name: "_default._" + s.prefix + "." + domain, Type: "TXT"}})
+	}
+	return levels, defaults
+}
+
+//fileRecords returns the hosts of the rules file, most
+//specific first: the exact hosts of each level, then the
+//wildcards of each parent (*.<parent>) and the top-level
+//default (*)
+func (s *Subfwd) fileRecords(labels []string, domain string) (hosts, wildcards []*record) {
+	if len(labels) == 0 {
+		hosts = append(hosts, &record{Name: domain, Type: "FILE"})
+	}
+	for i := 0; i < len(labels) && i < maxLevels; i++ {
+		level := strings.Join(labels[i:], ".") + "." + domain
+		parent := strings.Join(append(labels[i+1:], domain), ".")
+		hosts = append(hosts, &record{Name: level, Type: "FILE"})
+		wildcards = append(wildcards, &record{Name: "*." + parent, Type: "FILE"})
+	}
+	if n := len(wildcards); n == 0 || wildcards[n-1].Name != "*."+domain {
+		wildcards = append(wildcards, &record{Name: "*." + domain, Type: "FILE"})
+	}
+	return hosts, append(wildcards, &record{Name: "*", Type: "FILE"})
+}
+
+//ruled returns whether any of the given records has a rule
+func ruled(recs []*record) bool {
+	for _, rec := range recs {
+		if rec.Rule != nil {
+			return true
+		}
+	}
+	return false
+}
+
+//bogus returns the first DNSSEC validation failure
+func (res *resolution) bogus() *ValidationError {
+	for _, rec := range res.Records {
+		if verr, ok := rec.err.(*ValidationError); ok {
+			return verr
+		}
+	}
+	return nil
+}
+
+//lookupRecord fills in the given record, its rule
+//is the first of its TXT values which is valid, unless
+//it has weighted values, which are combined into a split
+func (s *Subfwd) lookupRecord(rec *record) {
+	if rec.Type == "URI" {
+		s.lookupURIRecord(rec)
+		return
+	} else if rec.Type == "FILE" {
+		rec.Rule = s.rules.Rule(rec.Name)
+		return
+	}
+	txts, err := s.resolver.LookupTXT(rec.Name)
+	if err != nil {
+		rec.err = err
+		if !isNotFound(err) {
+			rec.Errors = append(rec.Errors, err.Error())
+		}
+		return
+	}
+	rec.TXT = txts
+	txts, err = joinFragments(txts)
+	if err != nil {
+		s.logf("Invalid record %s: %s", rec.Name, err)
+		rec.Errors = append(rec.Errors, err.Error())
+	}
+	var split *Rule
+	for _, txt := range txts {
+		rule, err := ParseRule(txt)
+		if err == errNotRule {
+			continue
+		} else if err != nil {
+			s.logf("Invalid record %s: %s", rec.Name, err)
+			rec.Errors = append(rec.Errors, err.Error())
+			continue
+		}
+		if len(rule.Variants) > 0 {
+			if split == nil {
+				split = rule
+			} else {
+				split.Variants = append(split.Variants, rule.Variants...)
+			}
+		} else if rec.Rule == nil {
+			rec.Rule = rule
+		}
+	}
+	if split == nil {
+		return
+	}
+	if err := checkWeights(split.Variants); err != nil {
+		s.logf("Invalid record %s: %s", rec.Name, err)
+		rec.Errors = append(rec.Errors, err.Error())
+		return
+	}
+	rec.Rule = split
+}
+
+//lookupURIRecord fills in the given record, its rule
+//targets one of its valid URIs (see selectURI)
+func (s *Subfwd) lookupURIRecord(rec *record) {
+	uris, _, err := s.resolver.(URIResolver).LookupURI(rec.Name)
+	if err != nil {
+		rec.err = err
+		if !isNotFound(err) {
+			rec.Errors = append(rec.Errors, err.Error())
+		}
+		return
+	}
+	rec.URI = uris
+	valid := []*URI{}
+	for _, u := range uris {
+		if err := checkURL(u.Target); err != nil {
+			s.logf("Invalid record %s: %s", rec.Name, err)
+			rec.Errors = append(rec.Errors, err.Error())
+			continue
+		}
+		valid = append(valid, u)
+	}
+	if u := selectURI(valid); u != nil {
+		rec.Rule = &Rule{URL: u.Target}
+	}
+}
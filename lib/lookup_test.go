@@ -0,0 +1,145 @@
+package subfwd
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//txtNames returns the names of the given TXT records
+func txtNames(recs []*record) string {
+	n := []string{}
+	for _, rec := range recs {
+		if rec.Type == "TXT" {
+			n = append(n, rec.Name)
+		}
+	}
+	return strings.Join(n, " ")
+}
+
+func TestCodes(t *testing.T) {
+	s, f := newTestServer(t, Config{Redirect: 307})
+	f.SetTXT("subfwd308-a.example.com", "http://a.com")
+	f.SetTXT("subfwd-b.example.com", "http://b.com")
+	f.SetTXT("subfwd301-c.example.com", "v=subfwd1; url=http://c.com; code=303")
+	f.SetTXT("subfwd301-d.example.com", "http://d301.com")
+	f.SetTXT("subfwd-d.example.com", "http://d.com")
+	for u, want := range map[string]string{
+		"http://a.example.com/": "308 http://a.com",
+		"http://b.example.com/": "307 http://b.com",
+		"http://c.example.com/": "303 http://c.com",
+		//the plain record takes precedence
+		"http://d.example.com/": "307 http://d.com",
+	} {
+		w := do(s, "GET", u)
+		if got := strconv.Itoa(w.Code) + " " + w.Header().Get("Location"); got != want {
+			t.Errorf("%s: got %q, want %q", u, got, want)
+		}
+	}
+	if _, err := New(Config{Redirect: 200}); err == nil {
+		t.Error("expected an invalid redirect code error")
+	}
+}
+
+func TestCodeLookups(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-a.example.com", "http://a.com")
+	f.SetTXT("subfwd303-b.example.com", "http://b.com")
+	f.SetTXT("subproxy-c.example.com", "http://c.com")
+	codes := "subfwd301-x.example.com subfwd302-x.example.com subfwd303-x.example.com subfwd307-x.example.com subfwd308-x.example.com"
+	for host, want := range map[string]string{
+		//code records are skipped when the plain record has a rule
+		"a.example.com": "subproxy-a.example.com subfwd-a.example.com subfwd-default.example.com",
+		"b.example.com": "subproxy-b.example.com " + strings.Replace(codes, "-x.", "-b.", -1) + " subfwd-b.example.com subfwd-default.example.com",
+		//or when a record before it has a rule
+		"c.example.com": "subproxy-c.example.com subfwd-c.example.com subfwd-default.example.com",
+		"x.example.com": "subproxy-x.example.com " + codes + " subfwd-x.example.com subfwd-default.example.com",
+	} {
+		res, err := s.resolve(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := txtNames(res.Records); got != want {
+			t.Errorf("%s: got %q, want %q", host, got, want)
+		}
+	}
+	if res, _ := s.resolve("b.example.com"); res.Rule == nil || res.Rule.URL != "http://b.com" || res.Code != 303 {
+		t.Errorf("got %+v", res)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-a.b.example.com", "http://ab.com")
+	f.SetTXT("subfwd-default.b.example.com", "http://bdefault.com")
+	f.SetTXT("subfwd-c.example.com", "http://c.com")
+	f.SetTXT("subfwd-default.example.com", "http://default.com")
+	for u, want := range map[string]string{
+		"http://a.b.example.com/": "http://ab.com",
+		"http://x.b.example.com/": "http://bdefault.com",
+		//a parent level before the domain default
+		"http://x.c.example.com/": "http://c.com",
+		"http://b.example.com/":   "http://default.com",
+		"http://y.example.com/":   "http://default.com",
+		"http://example.com/":     "http://default.com",
+	} {
+		w := do(s, "GET", u)
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != want {
+			t.Errorf("%s: got %d %q, want %q", u, w.Code, loc, want)
+		}
+	}
+	//only the deepest levels are walked
+	res, err := s.resolve("a.b.c.d.e.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []string{}
+	for _, rec := range res.Records {
+		if rec.Type == "TXT" && strings.HasPrefix(rec.Name, "subfwd-") {
+			plain = append(plain, rec.Name)
+		}
+	}
+	want := "subfwd-a.b.c.d.e.example.com subfwd-default.b.c.d.e.example.com " +
+		"subfwd-b.c.d.e.example.com subfwd-default.c.d.e.example.com " +
+		"subfwd-c.d.e.example.com subfwd-default.d.e.example.com " +
+		"subfwd-d.e.example.com subfwd-default.e.example.com " +
+		"subfwd-default.example.com"
+	if got := strings.Join(plain, " "); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNaming(t *testing.T) {
+	for _, c := range []struct {
+		naming, host, url string
+		proxy             bool
+		records           string
+	}{
+		{"legacy", "a", "http://old.com", false, "subproxy-a.example.com subfwd-a.example.com subfwd-default.example.com"},
+		{"underscore", "a", "http://new.com", true, "_subfwd.a.example.com"},
+		//the defaults are only looked up when a level has no rule
+		{"underscore", "b", "http://def.com", false, "_subfwd.b.example.com _default._subfwd.example.com"},
+		{"underscore", "x.y", "http://def.com", false, "_subfwd.x.y.example.com _default._subfwd.y.example.com _subfwd.y.example.com _default._subfwd.example.com"},
+		{"both", "a", "http://new.com", true, "_subfwd.a.example.com"},
+		{"both", "b", "http://oldb.com", false, "_subfwd.b.example.com subproxy-b.example.com subfwd-b.example.com subfwd-default.example.com"},
+	} {
+		s, f := newTestServer(t, Config{Naming: c.naming})
+		f.SetTXT("_subfwd.a.example.com", "v=subfwd1; url=http://new.com; mode=proxy")
+		f.SetTXT("subfwd-a.example.com", "http://old.com")
+		f.SetTXT("subfwd-b.example.com", "http://oldb.com")
+		f.SetTXT("_default._subfwd.example.com", "http://def.com")
+		res, err := s.resolve(c.host + ".example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Rule == nil || res.Rule.URL != c.url || res.Proxy != c.proxy {
+			t.Errorf("%s %s: got %+v", c.naming, c.host, res)
+		}
+		if got := txtNames(res.Records); got != c.records {
+			t.Errorf("%s %s: got %q, want %q", c.naming, c.host, got, c.records)
+		}
+	}
+	if _, err := New(Config{Naming: "x", Resolver: NewFakeResolver()}); err == nil {
+		t.Error("expected an invalid naming scheme error")
+	}
+}
@@ -0,0 +1,471 @@
+package subfwd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+//nsTTL is the time-to-live of the records
+//answered by the embedded name server
+const nsTTL = 60
+
+//nsIdleTimeout closes idle TCP connections
+const nsIdleTimeout = 10 * time.Second
+
+//maxUDPSize bounds UDP responses, whatever the client's
+//EDNS buffer size, larger responses are truncated
+const maxUDPSize = 1232
+
+//maxNSHandlers bounds the concurrent UDP queries
+//and the TCP connections served by the name server
+const maxNSHandlers = 64
+
+//typeHINFO answers ANY queries (RFC 8482)
+const typeHINFO = dnsmessage.Type(13)
+
+//maxTXTString is the longest string within a TXT record,
+//longer values are split into multiple strings
+const maxTXTString = 255
+
+//NameServer is an authoritative DNS server (UDP and TCP)
+//for the zones delegated to subfwd. Every name within a
+//zone has A and AAAA records of the server's addresses,
+//so all subdomains reach the HTTP side without a wildcard
+//CNAME, and TXT records are managed through the /records
+//admin API. Delegate a zone by adding NS records at its
+//parent along with a glue record for ns.<zone>, e.g.
+//
+//	links.example.com.     NS  ns.links.example.com.
+//	ns.links.example.com.  A   203.0.113.10
+//
+//NameServer is also a Resolver, which answers the TXT records
+//of its zones directly and passes other lookups to Next.
+type NameServer struct {
+	Zones  []string
+	Addrs  []net.IP
+	Path   string
+	Next   Resolver
+	Logf   func(string, ...interface{})
+	mut    sync.RWMutex
+	txts   map[string][]string
+	serial uint32
+}
+
+//NewNameServer creates a name server for the given zones and
+//addresses, its TXT records are stored in the given JSON file
+func NewNameServer(zones, addrs []string, path string) (*NameServer, error) {
+	ns := &NameServer{
+		Path:   path,
+		Logf:   func(string, ...interface{}) {},
+		txts:   map[string][]string{},
+		serial: uint32(time.Now().Unix()),
+	}
+	if len(zones) == 0 {
+		return nil, errors.New("name server requires a zone")
+	}
+	for _, z := range zones {
+		z = canonical(z)
+		if _, err := dnsmessage.NewName("hostmaster." + z + "."); err != nil || z == "" {
+			return nil, fmt.Errorf("invalid zone '%s'", z)
+		}
+		ns.Zones = append(ns.Zones, z)
+	}
+	for _, a := range addrs {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid name server address '%s'", a)
+		}
+		ns.Addrs = append(ns.Addrs, ip)
+	}
+	if len(ns.Addrs) == 0 {
+		return nil, errors.New("name server requires an address")
+	}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err == nil {
+			if err := json.Unmarshal(b, &ns.txts); err != nil {
+				return nil, fmt.Errorf("invalid records file %s: %s", path, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return ns, nil
+}
+
+//zoneOf returns the zone of the given name, if any
+func (ns *NameServer) zoneOf(name string) string {
+	zone := ""
+	for _, z := range ns.Zones {
+		if (name == z || strings.HasSuffix(name, "."+z)) && len(z) > len(zone) {
+			zone = z
+		}
+	}
+	return zone
+}
+
+//Records returns the TXT records of all names
+func (ns *NameServer) Records() map[string][]string {
+	ns.mut.RLock()
+	defer ns.mut.RUnlock()
+	all := map[string][]string{}
+	for name, txts := range ns.txts {
+		all[name] = append([]string{}, txts...)
+	}
+	return all
+}
+
+//SetTXT replaces the TXT records of the given name
+//(removing them when none are given) and saves them
+func (ns *NameServer) SetTXT(name string, txts ...string) error {
+	name = canonical(name)
+	if ns.zoneOf(name) == "" {
+		return fmt.Errorf("%s is not within a zone of this server", name)
+	}
+	ns.mut.Lock()
+	defer ns.mut.Unlock()
+	prev, existed := ns.txts[name]
+	if len(txts) == 0 {
+		delete(ns.txts, name)
+	} else {
+		ns.txts[name] = append([]string{}, txts...)
+	}
+	if err := ns.save(); err != nil {
+		if existed {
+			ns.txts[name] = prev
+		} else {
+			delete(ns.txts, name)
+		}
+		return err
+	}
+	ns.serial++
+	return nil
+}
+
+//save writes the records to the records file (under lock)
+func (ns *NameServer) save() error {
+	if ns.Path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(ns.txts, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ns.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ns.Path)
+}
+
+//LookupTXT returns the TXT records of the given name
+func (ns *NameServer) LookupTXT(name string) ([]string, error) {
+	txts, _, err := ns.LookupTXTTTL(name)
+	return txts, err
+}
+
+//LookupTXTTTL returns the TXT records of the given name, names
+//within the zones are answered from the records without a TTL
+//(so they are never cached), other names are looked up by Next
+func (ns *NameServer) LookupTXTTTL(name string) ([]string, time.Duration, error) {
+	name = canonical(name)
+	if ns.zoneOf(name) == "" {
+		if tr, ok := ns.Next.(TTLResolver); ok {
+			return tr.LookupTXTTTL(name)
+		}
+		txts, err := ns.Next.LookupTXT(name)
+		return txts, -1, err
+	}
+	ns.mut.RLock()
+	txts, ok := ns.txts[name]
+	ns.mut.RUnlock()
+	if !ok {
+		return nil, 0, notFound(name, "subfwd")
+	}
+	return append([]string{}, txts...), 0, nil
+}
+
+//LookupURI returns the URI records of the given name,
+//the zones have none, other names are looked up by Next
+func (ns *NameServer) LookupURI(name string) ([]*URI, time.Duration, error) {
+	if ns.zoneOf(canonical(name)) != "" {
+		return nil, 0, notFound(name, "subfwd")
+	}
+	ur, ok := ns.Next.(URIResolver)
+	if !ok {
+		return nil, 0, errNoURI
+	}
+	return ur.LookupURI(name)
+}
+
+//LookupCNAME returns the canonical name of the given name,
+//the zones have no CNAMEs, other names are looked up by Next
+func (ns *NameServer) LookupCNAME(name string) (string, error) {
+	if ns.zoneOf(canonical(name)) != "" {
+		return "", notFound(name, "subfwd")
+	}
+	return ns.Next.LookupCNAME(name)
+}
+
+//Listen binds the given address (host:port) over UDP
+//and TCP, then serves queries in the background
+func (ns *NameServer) Listen(addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		pc.Close()
+		return err
+	}
+	go ns.serveUDP(pc)
+	go ns.serveTCP(l)
+	return nil
+}
+
+func (ns *NameServer) serveUDP(pc net.PacketConn) {
+	buff := make([]byte, 65535)
+	sem := make(chan bool, maxNSHandlers)
+	for {
+		n, addr, err := pc.ReadFrom(buff)
+		if err != nil {
+			ns.Logf("Name server stopped: %s", err)
+			return
+		}
+		query := append([]byte{}, buff[:n]...)
+		sem <- true
+		go func() {
+			defer func() { <-sem }()
+			if resp := ns.answer(query, true); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}()
+	}
+}
+
+func (ns *NameServer) serveTCP(l net.Listener) {
+	sem := make(chan bool, maxNSHandlers)
+	for {
+		sem <- true
+		conn, err := l.Accept()
+		if err != nil {
+			<-sem
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			ns.Logf("Name server stopped: %s", err)
+			return
+		}
+		go func() {
+			defer func() { <-sem }()
+			ns.serveConn(conn)
+		}()
+	}
+}
+
+//serveConn answers the length-prefixed queries of a TCP connection
+func (ns *NameServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	l := make([]byte, 2)
+	for {
+		conn.SetDeadline(time.Now().Add(nsIdleTimeout))
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return
+		}
+		query := make([]byte, int(l[0])<<8|int(l[1]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		resp := ns.answer(query, false)
+		if resp == nil {
+			return
+		}
+		if _, err := conn.Write(append([]byte{byte(len(resp) >> 8), byte(len(resp))}, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+//answer returns the packed response to the given query, or
+//nil when it should be dropped. UDP responses larger than the
+//client's buffer (or maxUDPSize) are truncated, so it retries
+//over TCP.
+func (ns *NameServer) answer(query []byte, udp bool) []byte {
+	req := &dnsmessage.Message{}
+	if err := req.Unpack(query); err != nil || req.Response {
+		return nil
+	}
+	resp := &dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               req.ID,
+			Response:         true,
+			OpCode:           req.OpCode,
+			RecursionDesired: req.RecursionDesired,
+		},
+		Questions: req.Questions,
+	}
+	limit := 512
+	var opt *dnsmessage.Resource
+	for _, a := range req.Additionals {
+		if a.Header.Type == dnsmessage.TypeOPT {
+			if size := int(a.Header.Class); size > limit {
+				limit = size
+			}
+			if limit > maxUDPSize {
+				limit = maxUDPSize
+			}
+			opt = &dnsmessage.Resource{Body: &dnsmessage.OPTResource{}}
+			opt.Header.SetEDNS0(maxUDPSize, dnsmessage.RCodeSuccess, false)
+		}
+	}
+	if opt != nil {
+		resp.Additionals = append(resp.Additionals, *opt)
+	}
+	if req.OpCode != 0 {
+		resp.RCode = dnsmessage.RCodeNotImplemented
+	} else if len(req.Questions) != 1 {
+		resp.RCode = dnsmessage.RCodeFormatError
+	} else {
+		ns.resolve(req.Questions[0], resp)
+	}
+	b, err := resp.Pack()
+	if err != nil {
+		ns.Logf("Name server response failed: %s", err)
+		return nil
+	}
+	if udp && len(b) > limit {
+		resp.Truncated = true
+		resp.Answers, resp.Authorities, resp.Additionals = nil, nil, nil
+		if opt != nil {
+			resp.Additionals = append(resp.Additionals, *opt)
+		}
+		b, err = resp.Pack()
+		if err != nil {
+			return nil
+		}
+	}
+	return b
+}
+
+//resolve fills in the answers of the given question, all
+//names within a zone exist (with A and AAAA records) so
+//missing types have no answers (NODATA) rather than NXDOMAIN.
+//ANY queries are given a minimal answer (RFC 8482).
+func (ns *NameServer) resolve(q dnsmessage.Question, resp *dnsmessage.Message) {
+	name := canonical(q.Name.String())
+	zone := ns.zoneOf(name)
+	if zone == "" || q.Class != dnsmessage.ClassINET && q.Class != dnsmessage.ClassANY {
+		resp.RCode = dnsmessage.RCodeRefused
+		return
+	}
+	resp.Authoritative = true
+	hdr := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: nsTTL}
+	apex := name == zone
+	if q.Type == dnsmessage.TypeALL {
+		hdr.Type = typeHINFO
+		resp.Answers = append(resp.Answers, dnsmessage.Resource{
+			Header: hdr,
+			Body:   &dnsmessage.UnknownResource{Type: typeHINFO, Data: []byte("\x07RFC8482\x00")},
+		})
+		return
+	}
+	if q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeAAAA {
+		resp.Answers = append(resp.Answers, ns.addrs(hdr, q.Type)...)
+	}
+	if q.Type == dnsmessage.TypeTXT {
+		ns.mut.RLock()
+		txts := ns.txts[name]
+		ns.mut.RUnlock()
+		for _, txt := range txts {
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{
+				Header: hdr,
+				Body:   &dnsmessage.TXTResource{TXT: splitTXT(txt)},
+			})
+		}
+	}
+	if apex && q.Type == dnsmessage.TypeNS {
+		resp.Answers = append(resp.Answers, dnsmessage.Resource{
+			Header: hdr,
+			Body:   &dnsmessage.NSResource{NS: mustName("ns." + zone)},
+		})
+		//glue
+		glue := dnsmessage.ResourceHeader{Name: mustName("ns." + zone), Class: dnsmessage.ClassINET, TTL: nsTTL}
+		resp.Additionals = append(resp.Additionals, ns.addrs(glue, dnsmessage.TypeALL)...)
+	}
+	if apex && q.Type == dnsmessage.TypeSOA {
+		resp.Answers = append(resp.Answers, ns.soa(zone))
+	}
+	if len(resp.Answers) == 0 {
+		//the SOA allows negative caching
+		resp.Authorities = append(resp.Authorities, ns.soa(zone))
+	}
+}
+
+//addrs returns the A and/or AAAA records of the server
+func (ns *NameServer) addrs(hdr dnsmessage.ResourceHeader, qtype dnsmessage.Type) []dnsmessage.Resource {
+	rs := []dnsmessage.Resource{}
+	for _, ip := range ns.Addrs {
+		if ip4 := ip.To4(); ip4 != nil && qtype != dnsmessage.TypeAAAA {
+			a := &dnsmessage.AResource{}
+			copy(a.A[:], ip4)
+			rs = append(rs, dnsmessage.Resource{Header: hdr, Body: a})
+		} else if ip4 == nil && qtype != dnsmessage.TypeA {
+			a := &dnsmessage.AAAAResource{}
+			copy(a.AAAA[:], ip)
+			rs = append(rs, dnsmessage.Resource{Header: hdr, Body: a})
+		}
+	}
+	return rs
+}
+
+//soa returns the SOA record of the given zone, its
+//serial changes whenever the records are changed
+func (ns *NameServer) soa(zone string) dnsmessage.Resource {
+	ns.mut.RLock()
+	serial := ns.serial
+	ns.mut.RUnlock()
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: mustName(zone), Class: dnsmessage.ClassINET, TTL: nsTTL},
+		Body: &dnsmessage.SOAResource{
+			NS:      mustName("ns." + zone),
+			MBox:    mustName("hostmaster." + zone),
+			Serial:  serial,
+			Refresh: 3600,
+			Retry:   600,
+			Expire:  86400,
+			MinTTL:  nsTTL,
+		},
+	}
+}
+
+//splitTXT splits a TXT value into strings of at most 255
+//bytes, which resolvers join back together on lookup
+func splitTXT(txt string) []string {
+	strs := []string{}
+	for len(txt) > maxTXTString {
+		strs = append(strs, txt[:maxTXTString])
+		txt = txt[maxTXTString:]
+	}
+	return append(strs, txt)
+}
+
+//mustName converts a (valid) domain name
+func mustName(name string) dnsmessage.Name {
+	n, err := dnsmessage.NewName(canonical(name) + ".")
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
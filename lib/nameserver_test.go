@@ -0,0 +1,210 @@
+package subfwd
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+//listenNS serves the name server on a free local port
+func listenNS(t *testing.T, ns *NameServer) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+	if err := ns.Listen(addr); err != nil {
+		t.Skip("port in use: ", err)
+	}
+	return addr
+}
+
+func TestNameServerRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	s, f := newTestServer(t, Config{NSZones: []string{"links.example.com"}, NSAddrs: []string{"127.0.0.1"}, NSRecords: path, AdminToken: "tok"})
+	f.SetTXT("subfwd-x.other.com", "http://other.com")
+	req := func(method, url, body, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://subfwd.jpillora.com"+url, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.route(w, r)
+		return w
+	}
+	for _, c := range []struct {
+		method, url, body, token string
+		code                     int
+	}{
+		{"PUT", "/records?name=subfwd-go.links.example.com", `["https://golang.org"]`, "", 401},
+		{"PUT", "/records?name=subfwd-go.links.example.com", `["https://golang.org"]`, "bad", 401},
+		{"PUT", "/records?name=subfwd-go.links.example.com", `["https://golang.org"]`, "tok", 200},
+		{"PUT", "/records?name=subfwd-a.links.example.com", `["https://a.com"]`, "tok", 200},
+		//outside of the zones
+		{"PUT", "/records?name=subfwd-x.other.com", `["https://x.com"]`, "tok", 400},
+		//invalid rules
+		{"PUT", "/records?name=subfwd-bad.links.example.com", `["v=subfwd1; code=1"]`, "tok", 400},
+		{"DELETE", "/records?name=subfwd-a.links.example.com", "", "tok", 200},
+	} {
+		if w := req(c.method, c.url, c.body, c.token); w.Code != c.code {
+			t.Errorf("%s %s: got %d %s, want %d", c.method, c.url, w.Code, w.Body, c.code)
+		}
+	}
+	if w := req("GET", "/records", "", "tok"); !strings.Contains(w.Body.String(), "golang") || strings.Contains(w.Body.String(), "a.com") {
+		t.Errorf("got %s", w.Body)
+	}
+	//records within the zones are answered directly
+	for u, want := range map[string]string{
+		"http://go.links.example.com/": "https://golang.org",
+		"http://x.other.com/":          "http://other.com",
+	} {
+		if w := do(s, "GET", u); w.Header().Get("Location") != want {
+			t.Errorf("%s: got %d %q, want %q", u, w.Code, w.Header().Get("Location"), want)
+		}
+	}
+	if w := do(s, "GET", "http://a.links.example.com/"); w.Code != 404 {
+		t.Errorf("deleted: got %d", w.Code)
+	}
+	//and stored
+	b, err := ioutil.ReadFile(path)
+	if err != nil || !strings.Contains(string(b), "golang") {
+		t.Errorf("got %s %v", b, err)
+	}
+	ns, err := NewNameServer([]string{"links.example.com"}, []string{"1.2.3.4"}, path)
+	if err != nil || len(ns.Records()) != 1 {
+		t.Errorf("got %v %v", ns.Records(), err)
+	}
+	if _, err := New(Config{NSListen: ":53"}); err == nil {
+		t.Error("expected a missing zones error")
+	}
+}
+
+func TestNameServerDNS(t *testing.T) {
+	ns, err := NewNameServer([]string{"links.example.com"}, []string{"127.0.0.1", "::1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listenNS(t, ns)
+	long := "https://example.com/" + strings.Repeat("a", 600)
+	ns.SetTXT("subfwd-long.links.example.com", long, "other")
+	for _, network := range []string{"udp", "tcp"} {
+		u := NewUpstreamResolver(addr, network)
+		txts, ttl, err := u.LookupTXTTTL("subfwd-long.LINKS.example.com")
+		if err != nil || len(txts) != 2 || (txts[0] != long && txts[1] != long) || ttl != time.Minute {
+			t.Errorf("%s: got %d values %s %v", network, len(txts), ttl, err)
+		}
+		for _, c := range []struct {
+			name    string
+			qtype   dnsmessage.Type
+			answers int
+		}{
+			{"links.example.com", dnsmessage.TypeA, 1},
+			{"any.thing.links.example.com", dnsmessage.TypeA, 1},
+			{"any.thing.links.example.com", dnsmessage.TypeAAAA, 1},
+			{"links.example.com", dnsmessage.TypeSOA, 1},
+			{"links.example.com", dnsmessage.TypeNS, 1},
+			{"any.thing.links.example.com", dnsmessage.TypeNS, 0},
+			{"links.example.com", dnsmessage.TypeTXT, 0},
+			{"links.example.com", dnsmessage.TypeMX, 0},
+		} {
+			msg, err := u.exchange(c.name, c.qtype, false)
+			if err != nil || !msg.Authoritative || len(msg.Answers) != c.answers {
+				t.Errorf("%s %s %s: got %+v %v", network, c.name, c.qtype, msg, err)
+			}
+		}
+		//other zones are refused
+		if _, err := u.exchange("example.com", dnsmessage.TypeA, false); err == nil || !strings.Contains(err.Error(), "Refused") {
+			t.Errorf("%s: expected refused, got %v", network, err)
+		}
+	}
+	//large responses are truncated over UDP
+	many := []string{}
+	for i := 0; i < 20; i++ {
+		many = append(many, strings.Repeat("x", 100))
+	}
+	ns.SetTXT("big.links.example.com", many...)
+	u := NewUpstreamResolver(addr, "udp")
+	q, _ := newQuery("big.links.example.com", dnsmessage.TypeTXT, 7, false)
+	if msg, err := u.roundTrip("udp", q); err != nil || !msg.Truncated {
+		t.Errorf("expected truncation, got %v", err)
+	}
+	if txts, err := u.LookupTXT("big.links.example.com"); len(txts) != 20 {
+		t.Errorf("got %d values %v", len(txts), err)
+	}
+}
+
+//TestNameServerAmplification checks UDP responses are
+//bounded, whatever the query's type and buffer size
+func TestNameServerAmplification(t *testing.T) {
+	ns, err := NewNameServer([]string{"links.example.com"}, []string{"127.0.0.1", "::1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listenNS(t, ns)
+	many := []string{}
+	for i := 0; i < 20; i++ {
+		many = append(many, strings.Repeat("x", 100))
+	}
+	ns.SetTXT("big.links.example.com", many...)
+	u := NewUpstreamResolver(addr, "udp")
+	query := func(qtype dnsmessage.Type) *dnsmessage.Message {
+		msg := dnsmessage.Message{Questions: []dnsmessage.Question{{Name: mustName("big.links.example.com"), Type: qtype, Class: dnsmessage.ClassINET}}}
+		opt := dnsmessage.Resource{Body: &dnsmessage.OPTResource{}}
+		opt.Header.SetEDNS0(65535, dnsmessage.RCodeSuccess, false)
+		msg.Additionals = append(msg.Additionals, opt)
+		q, _ := msg.Pack()
+		resp, err := u.roundTrip("udp", q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	//ANY has a minimal answer
+	if resp := query(dnsmessage.TypeALL); len(resp.Answers) != 1 || resp.Answers[0].Header.Type != typeHINFO {
+		t.Errorf("got %+v", resp.Answers)
+	}
+	//large buffers are capped
+	if resp := query(dnsmessage.TypeTXT); !resp.Truncated || len(resp.Answers) != 0 {
+		t.Errorf("expected truncation, got %d answers", len(resp.Answers))
+	}
+	if txts, err := u.LookupTXT("big.links.example.com"); len(txts) != 20 {
+		t.Errorf("got %d values %v", len(txts), err)
+	}
+}
+
+func TestSplitTXT(t *testing.T) {
+	for _, c := range []struct {
+		n    int
+		want []int
+	}{
+		{0, []int{0}},
+		{254, []int{254}},
+		{255, []int{255}},
+		{256, []int{255, 1}},
+		{510, []int{255, 255}},
+		{511, []int{255, 255, 1}},
+	} {
+		txt := strings.Repeat("x", c.n)
+		strs := splitTXT(txt)
+		lens := []int{}
+		for _, s := range strs {
+			lens = append(lens, len(s))
+		}
+		if strings.Join(strs, "") != txt || len(lens) != len(c.want) {
+			t.Errorf("%d: got %v, want %v", c.n, lens, c.want)
+			continue
+		}
+		for i := range lens {
+			if lens[i] != c.want[i] {
+				t.Errorf("%d: got %v, want %v", c.n, lens, c.want)
+			}
+		}
+	}
+}
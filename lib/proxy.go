@@ -0,0 +1,231 @@
+package subfwd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+)
+
+//proxyForwardKey holds the forward of a proxied request
+type proxyForwardKey struct{}
+
+//maxProxies bounds the records tracked by a proxy pool
+const maxProxies = 1024
+
+//proxyPool holds a reverse proxy per target origin
+//(scheme://host), each with its own clone of the tuned
+//transport, so connections to each origin are pooled and
+//reused. When a record's target moves to another origin,
+//and no other record uses the old one, its idle
+//connections are closed
+type proxyPool struct {
+	Logf      func(string, ...interface{})
+	transport *http.Transport
+	flush     time.Duration
+	mut       sync.Mutex
+	records   map[string]string
+	origins   map[string]*pooledProxy
+}
+
+//pooledProxy is the proxy of an origin
+//and the number of records using it
+type pooledProxy struct {
+	transport *http.Transport
+	proxy     *httputil.ReverseProxy
+	records   int
+}
+
+//newProxyPool creates a proxy pool with a transport tuned by
+//the given configuration, zero values use the Go defaults
+func newProxyPool(c Config) *proxyPool {
+	dialer := &net.Dialer{
+		Timeout:   c.ProxyDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &proxyPool{
+		Logf: func(string, ...interface{}) {},
+		transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			MaxIdleConnsPerHost:   c.ProxyIdleConns,
+			IdleConnTimeout:       c.ProxyIdleTimeout,
+			TLSHandshakeTimeout:   c.ProxyTLSTimeout,
+			ResponseHeaderTimeout: c.ProxyHeaderTimeout,
+			ExpectContinueTimeout: time.Second,
+			ForceAttemptHTTP2:     c.ProxyHTTP2,
+		},
+		flush:   c.FlushInterval,
+		records: map[string]string{},
+		origins: map[string]*pooledProxy{},
+	}
+}
+
+//get returns the proxy of the record's target origin
+func (p *proxyPool) get(name string, target *url.URL) *pooledProxy {
+	origin := target.Scheme + "://" + target.Host
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if old, ok := p.records[name]; ok && old == origin {
+		return p.origins[origin]
+	} else if ok {
+		p.Logf("Proxy target of %s changed from %s to %s", name, old, origin)
+		p.release(old)
+	} else if len(p.records) >= maxProxies {
+		for o := range p.origins {
+			p.origins[o].transport.CloseIdleConnections()
+		}
+		p.records = map[string]string{}
+		p.origins = map[string]*pooledProxy{}
+	}
+	pp, ok := p.origins[origin]
+	if !ok {
+		pp = &pooledProxy{transport: p.transport.Clone()}
+		pp.proxy = &httputil.ReverseProxy{
+			Director:      proxyDirector,
+			Transport:     pp.transport,
+			FlushInterval: p.flush,
+			ErrorHandler:  p.proxyError,
+		}
+		p.origins[origin] = pp
+	}
+	pp.records++
+	p.records[name] = origin
+	return pp
+}
+
+//release drops a record from the proxy of an origin, the
+//proxy and its idle connections are closed once unused
+func (p *proxyPool) release(origin string) {
+	pp := p.origins[origin]
+	if pp.records--; pp.records > 0 {
+		return
+	}
+	delete(p.origins, origin)
+	pp.transport.CloseIdleConnections()
+}
+
+//serve proxies the request to the target of the record's
+//forward, the response (or upgraded connection) is closed
+//once it has lasted the timeout, or been idle for the idle timeout
+func (p *proxyPool) serve(w http.ResponseWriter, r *http.Request, name string, f *forward) {
+	pp := p.get(name, f.target)
+	ctx, cancel := context.WithCancel(r.Context())
+	if f.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(r.Context(), f.Timeout)
+	}
+	defer cancel()
+	sw := &streamWriter{ResponseWriter: w, rc: http.NewResponseController(w), idle: f.Idle}
+	if f.Idle > 0 {
+		sw.timer = time.AfterFunc(f.Idle, cancel)
+		defer sw.timer.Stop()
+	}
+	//the context replaces the server's read and write timeouts
+	sw.rc.SetReadDeadline(time.Time{})
+	sw.rc.SetWriteDeadline(time.Time{})
+	r = r.WithContext(context.WithValue(ctx, proxyForwardKey{}, f))
+	pp.proxy.ServeHTTP(sw, r)
+}
+
+//proxyDirector points the outgoing request at its target
+func proxyDirector(req *http.Request) {
+	f := req.Context().Value(proxyForwardKey{}).(*forward)
+	host := req.Host
+	//target already includes the passed path and query
+	u := *f.target
+	req.URL = &u
+	if !f.KeepHost {
+		req.Host = u.Host //fix hostname
+	}
+	setForwarded(req, f.Forwarded, host)
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "") //disable default
+	}
+}
+
+//streamWriter restarts the idle timer of a proxied
+//response whenever data is written, a write which blocks
+//for the idle timeout (a stalled client) also fails
+type streamWriter struct {
+	http.ResponseWriter
+	rc    *http.ResponseController
+	idle  time.Duration
+	timer *time.Timer
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	sw.touch()
+	if sw.timer != nil {
+		sw.rc.SetWriteDeadline(time.Now().Add(sw.idle))
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+//touch restarts the idle timer
+func (sw *streamWriter) touch() {
+	if sw.timer != nil {
+		sw.timer.Reset(sw.idle)
+	}
+}
+
+//Hijack takes over the connection of an upgrade (e.g. a
+//WebSocket), which keeps the timeouts of the response
+func (sw *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := sw.rc.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	if sw.timer != nil {
+		conn = &streamConn{Conn: conn, touch: sw.touch}
+	}
+	return conn, brw, nil
+}
+
+//Unwrap allows the http.ResponseController
+//of the proxy to flush the response
+func (sw *streamWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+//streamConn restarts the idle timer of an upgraded
+//connection whenever data is read or written
+type streamConn struct {
+	net.Conn
+	touch func()
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *streamConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+//CloseWrite allows the proxy to pass on the
+//end of the target's stream (a half-close)
+func (c *streamConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("close write not supported")
+}
+
+func (p *proxyPool) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	p.Logf("Proxy failed for %s: %s", r.URL.Host, err)
+	w.WriteHeader(502)
+	w.Write([]byte("Proxy failed [" + err.Error() + "]"))
+}
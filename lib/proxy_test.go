@@ -0,0 +1,282 @@
+package subfwd
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//upstream is a proxied target which counts its connections
+type upstream struct {
+	*httptest.Server
+	conns, closed int64
+}
+
+func newUpstream(tls bool) *upstream {
+	u := &upstream{}
+	u.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.Write([]byte("ok " + r.URL.Path))
+	}))
+	u.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&u.conns, 1)
+		} else if state == http.StateClosed {
+			atomic.AddInt64(&u.closed, 1)
+		}
+	}
+	if tls {
+		u.EnableHTTP2 = true
+		u.StartTLS()
+	} else {
+		u.Start()
+	}
+	return u
+}
+
+//discard is a response writer which drops the response
+type discard struct{ h http.Header }
+
+func (d *discard) Header() http.Header         { return d.h }
+func (d *discard) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discard) WriteHeader(int)             {}
+
+func TestProxyPool(t *testing.T) {
+	up1, up2 := newUpstream(false), newUpstream(false)
+	defer up1.Close()
+	defer up2.Close()
+	p := newProxyPool(Config{ProxyIdleConns: 4})
+	t1, _ := url.Parse(up1.URL + "/a")
+	t2, _ := url.Parse(up2.URL + "/b")
+	//sequential requests reuse a connection per target
+	for i := 0; i < 10; i++ {
+		for name, target := range map[string]*url.URL{"a": t1, "b": t2} {
+			w := httptest.NewRecorder()
+			p.serve(w, httptest.NewRequest("GET", "http://x.example.com/", nil), name, &forward{target: target})
+			if want := "ok " + target.Path; w.Body.String() != want {
+				t.Fatalf("got %q, want %q", w.Body.String(), want)
+			}
+		}
+	}
+	if c1, c2 := atomic.LoadInt64(&up1.conns), atomic.LoadInt64(&up2.conns); c1 != 1 || c2 != 1 {
+		t.Errorf("got %d and %d connections", c1, c2)
+	}
+	bad, _ := url.Parse("http://127.0.0.1:1/")
+	w := httptest.NewRecorder()
+	p.serve(w, httptest.NewRequest("GET", "http://x.example.com/", nil), "c", &forward{target: bad})
+	if w.Code != 502 {
+		t.Errorf("got %d", w.Code)
+	}
+}
+
+func TestProxyPoolEviction(t *testing.T) {
+	up1, up2 := newUpstream(false), newUpstream(false)
+	defer up1.Close()
+	defer up2.Close()
+	p := newProxyPool(Config{ProxyIdleConns: 4})
+	t1, _ := url.Parse(up1.URL + "/a")
+	t2, _ := url.Parse(up2.URL + "/b")
+	serve := func(name string, target *url.URL) {
+		p.serve(httptest.NewRecorder(), httptest.NewRequest("GET", "http://x.example.com/", nil), name, &forward{target: target})
+	}
+	closed := func(u *upstream) int64 {
+		time.Sleep(50 * time.Millisecond)
+		return atomic.LoadInt64(&u.closed)
+	}
+	serve("a", t1)
+	serve("b", t1)
+	//the old origin is kept while another record uses it
+	serve("a", t2)
+	if n := closed(up1); n != 0 {
+		t.Errorf("got %d closed connections while in use", n)
+	}
+	serve("b", t1)
+	if c := atomic.LoadInt64(&up1.conns); c != 1 {
+		t.Errorf("got %d connections, want the idle one reused", c)
+	}
+	//once unused, its idle connections are closed
+	serve("b", t2)
+	if n := closed(up1); n != 1 {
+		t.Errorf("got %d closed connections, want 1", n)
+	}
+	if n := len(p.origins); n != 1 {
+		t.Errorf("got %d origins, want 1", n)
+	}
+}
+
+//streamUpstream sends an event stream of five events with
+//the given pause between them, or echoes upgraded connections
+func streamUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "echo" {
+			w.Header().Set("Connection", "Upgrade")
+			w.Header().Set("Upgrade", "echo")
+			w.WriteHeader(101)
+			conn, brw, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			brw.Flush()
+			io.Copy(conn, conn)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		pause, _ := time.ParseDuration(r.URL.Query().Get("pause"))
+		for i := 0; i < 5; i++ {
+			w.Write([]byte("data: x\n\n"))
+			http.NewResponseController(w).Flush()
+			time.Sleep(pause)
+		}
+		w.Write([]byte("data: end\n\n"))
+	}))
+}
+
+func TestStreams(t *testing.T) {
+	up := streamUpstream()
+	defer up.Close()
+	s, f := newTestServer(t, Config{ReadTimeout: 300 * time.Millisecond, WriteTimeout: 300 * time.Millisecond, StreamIdle: time.Second, FlushInterval: -1})
+	f.SetTXT("subproxy-sse.example.com", up.URL)
+	f.SetTXT("subfwd-idle.example.com", "v=subfwd1; mode=proxy; url="+up.URL+"; idle=200ms")
+	f.SetTXT("subfwd-total.example.com", "v=subfwd1; mode=proxy; url="+up.URL+"; timeout=500ms; idle=0")
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = s.server
+	ts.Start()
+	defer ts.Close()
+	for _, c := range []struct {
+		host, pause string
+		complete    bool
+	}{
+		//streams outlast the server's write timeout
+		{"sse.example.com", "200ms", true},
+		{"idle.example.com", "100ms", true},
+		{"idle.example.com", "400ms", false},
+		{"total.example.com", "200ms", false},
+	} {
+		req, _ := http.NewRequest("GET", ts.URL+"/?pause="+c.pause, nil)
+		req.Host = c.host
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if complete := strings.Contains(string(b), "end"); complete != c.complete {
+			t.Errorf("%s pause=%s: got %q", c.host, c.pause, b)
+		} else if !strings.HasPrefix(string(b), "data: x") {
+			t.Errorf("%s pause=%s: got %q, expected the stream to be flushed", c.host, c.pause, b)
+		}
+	}
+}
+
+func TestUpgradeStreams(t *testing.T) {
+	up := streamUpstream()
+	defer up.Close()
+	s, f := newTestServer(t, Config{ReadTimeout: 300 * time.Millisecond, WriteTimeout: 300 * time.Millisecond, StreamIdle: time.Second})
+	f.SetTXT("subfwd-ws.example.com", "v=subfwd1; mode=proxy; url="+up.URL+"; idle=400ms")
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = s.server
+	ts.Start()
+	defer ts.Close()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: ws.example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n"))
+	buf := make([]byte, 4096)
+	n, _ := conn.Read(buf)
+	if !strings.HasPrefix(string(buf[:n]), "HTTP/1.1 101") {
+		t.Fatalf("got %q", buf[:n])
+	}
+	//active connections outlast the server's timeouts
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		time.Sleep(250 * time.Millisecond)
+		conn.Write([]byte("ping"))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if string(buf[:n]) != "ping" {
+			t.Fatalf("echo %d: got %q %v", i, buf[:n], err)
+		}
+	}
+	//and are closed once idle
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(buf); err == nil || isTimeout(err) || time.Since(start) > 1800*time.Millisecond {
+		t.Errorf("expected the idle connection to be closed, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestStreamRules(t *testing.T) {
+	rule, err := ParseRule("v=subfwd1; url=http://a.com; timeout=0; idle=5m")
+	if err != nil || rule.Timeout == nil || *rule.Timeout != 0 || rule.Idle == nil || *rule.Idle != 5*time.Minute {
+		t.Errorf("got %+v %v", rule, err)
+	}
+	for txt, want := range map[string]string{
+		"v=subfwd1; url=http://a.com; timeout=x": "invalid timeout 'x'",
+		"v=subfwd1; url=http://a.com; idle=-1s":  "invalid idle '-1s'",
+	} {
+		if _, err := ParseRule(txt); err == nil || err.Error() != want {
+			t.Errorf("%q: got %v, want %q", txt, err, want)
+		}
+	}
+}
+
+//benchmarkProxy proxies parallel requests to the upstream,
+//reporting the connections it accepted per request
+func benchmarkProxy(b *testing.B, u *upstream, serve func(w http.ResponseWriter, r *http.Request, target *url.URL)) {
+	defer u.Close()
+	target, _ := url.Parse(u.URL + "/x")
+	b.SetParallelism(32)
+	atomic.StoreInt64(&u.conns, 0)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			serve(&discard{http.Header{}}, httptest.NewRequest("GET", "http://a.example.com/x", nil), target)
+		}
+	})
+	b.ReportMetric(float64(atomic.LoadInt64(&u.conns))/float64(b.N), "conns/op")
+}
+
+func benchmarkPooled(b *testing.B, tls bool) {
+	u := newUpstream(tls)
+	p := newProxyPool(Config{ProxyIdleConns: 64, ProxyHTTP2: true})
+	if tls {
+		p.transport.TLSClientConfig = u.Client().Transport.(*http.Transport).TLSClientConfig
+	}
+	benchmarkProxy(b, u, func(w http.ResponseWriter, r *http.Request, target *url.URL) {
+		p.serve(w, r, "a", &forward{target: target})
+	})
+}
+
+//benchmarkPerRequest creates a proxy and transport per
+//request with the default settings, as before proxies were pooled
+func benchmarkPerRequest(b *testing.B, tls bool) {
+	u := newUpstream(tls)
+	defaults := http.DefaultTransport.(*http.Transport)
+	benchmarkProxy(b, u, func(w http.ResponseWriter, r *http.Request, target *url.URL) {
+		tr := defaults.Clone()
+		defer tr.CloseIdleConnections()
+		if tls {
+			tr.TLSClientConfig = u.Client().Transport.(*http.Transport).TLSClientConfig
+		}
+		p := &httputil.ReverseProxy{Transport: tr, Director: func(req *http.Request) {
+			u := *target
+			req.URL = &u
+			req.Host = u.Host
+		}}
+		p.ServeHTTP(w, r)
+	})
+}
+
+func BenchmarkProxyPooled(b *testing.B)        { benchmarkPooled(b, false) }
+func BenchmarkProxyPerRequest(b *testing.B)    { benchmarkPerRequest(b, false) }
+func BenchmarkTLSProxyPooled(b *testing.B)     { benchmarkPooled(b, true) }
+func BenchmarkTLSProxyPerRequest(b *testing.B) { benchmarkPerRequest(b, true) }
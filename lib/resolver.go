@@ -0,0 +1,475 @@
+package subfwd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+//Resolver looks up the DNS records used
+//by subfwd to forward requests and to
+//verify domain setups
+type Resolver interface {
+	LookupTXT(name string) ([]string, error)
+	LookupCNAME(name string) (string, error)
+}
+
+//TTLResolver is implemented by resolvers which can
+//report the time-to-live of the TXT records they return
+type TTLResolver interface {
+	LookupTXTTTL(name string) ([]string, time.Duration, error)
+}
+
+//NewResolver creates a resolver from an address,
+//an empty address uses the system resolver and
+//an https:// URL uses DNS-over-HTTPS
+func NewResolver(addr string) (Resolver, error) {
+	if addr == "" {
+		return NewSystemResolver(), nil
+	}
+	if strings.HasPrefix(addr, "https://") {
+		return NewDoHResolver(addr), nil
+	}
+	network := "udp"
+	if strings.HasPrefix(addr, "tcp://") {
+		network = "tcp"
+		addr = strings.TrimPrefix(addr, "tcp://")
+	} else if strings.HasPrefix(addr, "udp://") {
+		addr = strings.TrimPrefix(addr, "udp://")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+	}
+	if host, _, err := net.SplitHostPort(addr); err != nil || host == "" ||
+		strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return nil, errors.New("invalid DNS server address: " + addr)
+	}
+	return NewUpstreamResolver(addr, network), nil
+}
+
+//resolvConf lists the system's nameservers
+var resolvConf = "/etc/resolv.conf"
+
+//SystemResolver uses the operating system's resolver (via
+//the net package). URI records, which the net package does
+//not support, are queried from the first nameserver of
+//resolv.conf (Upstream), when there is one.
+type SystemResolver struct {
+	Upstream *UpstreamResolver
+}
+
+//NewSystemResolver creates a SystemResolver
+func NewSystemResolver() *SystemResolver {
+	r := &SystemResolver{}
+	if addr := nameserver(resolvConf); addr != "" {
+		r.Upstream = NewUpstreamResolver(addr, "udp")
+	}
+	return r
+}
+
+//LookupTXT returns the TXT records of the given name
+func (*SystemResolver) LookupTXT(name string) ([]string, error) {
+	return net.LookupTXT(name)
+}
+
+//LookupCNAME returns the canonical name of the given name
+func (*SystemResolver) LookupCNAME(name string) (string, error) {
+	return net.LookupCNAME(name)
+}
+
+//LookupURI returns the URI records of the given
+//name along with their smallest time-to-live
+func (r *SystemResolver) LookupURI(name string) ([]*URI, time.Duration, error) {
+	if r.Upstream == nil {
+		return nil, 0, errNoURI
+	}
+	return r.Upstream.LookupURI(name)
+}
+
+//nameserver returns the address of the
+//first nameserver of a resolv.conf file
+func nameserver(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return ""
+}
+
+//UpstreamResolver sends queries directly to a
+//single DNS server over UDP or TCP. UDP queries
+//are retried over TCP when the answer is truncated.
+type UpstreamResolver struct {
+	Addr    string
+	Net     string
+	Timeout time.Duration
+}
+
+//NewUpstreamResolver creates a resolver which
+//queries the DNS server at addr (host:port)
+func NewUpstreamResolver(addr, network string) *UpstreamResolver {
+	return &UpstreamResolver{
+		Addr:    addr,
+		Net:     network,
+		Timeout: 5 * time.Second,
+	}
+}
+
+//LookupTXT returns the TXT records of the given name
+func (u *UpstreamResolver) LookupTXT(name string) ([]string, error) {
+	txts, _, err := u.LookupTXTTTL(name)
+	return txts, err
+}
+
+//LookupTXTTTL returns the TXT records of the given
+//name along with their smallest time-to-live
+func (u *UpstreamResolver) LookupTXTTTL(name string) ([]string, time.Duration, error) {
+	msg, err := u.exchange(name, dnsmessage.TypeTXT, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	return txtAnswers(msg, name, u.Addr)
+}
+
+//LookupURI returns the URI records of the given
+//name along with their smallest time-to-live
+func (u *UpstreamResolver) LookupURI(name string) ([]*URI, time.Duration, error) {
+	msg, err := u.exchange(name, typeURI, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	return uriAnswers(msg, name, u.Addr)
+}
+
+//LookupCNAME returns the canonical name of the given name
+func (u *UpstreamResolver) LookupCNAME(name string) (string, error) {
+	msg, err := u.exchange(name, dnsmessage.TypeCNAME, false)
+	if err != nil {
+		return "", err
+	}
+	return cnameAnswer(msg, name, u.Addr)
+}
+
+func (u *UpstreamResolver) exchange(name string, qtype dnsmessage.Type, dnssec bool) (*dnsmessage.Message, error) {
+	//random IDs make spoofed (off-path) responses hard to guess
+	b := make([]byte, 2)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint16(b)
+	query, err := newQuery(name, qtype, id, dnssec)
+	if err != nil {
+		return nil, err
+	}
+	network := u.Net
+	if network == "" {
+		network = "udp"
+	}
+	msg, err := u.roundTrip(network, query)
+	if err == nil && msg.Truncated && network == "udp" {
+		msg, err = u.roundTrip("tcp", query)
+	}
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: u.Addr, IsTimeout: isTimeout(err)}
+	}
+	return checkRCode(msg, name, u.Addr)
+}
+
+//roundTrip sends the query and returns its response, UDP
+//datagrams which do not answer the query are ignored
+func (u *UpstreamResolver) roundTrip(network string, query []byte) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), u.Timeout)
+	defer cancel()
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, network, u.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if network != "tcp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		b := make([]byte, 65535)
+		for {
+			n, err := conn.Read(b)
+			if err != nil {
+				return nil, err
+			}
+			if msg, err := checkResponse(query, b[:n]); err == nil {
+				return msg, nil
+			}
+		}
+	}
+	//tcp messages are prefixed with their length
+	b := make([]byte, 2+len(query))
+	b[0], b[1] = byte(len(query)>>8), byte(len(query))
+	copy(b[2:], query)
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+	l := make([]byte, 2)
+	if _, err := io.ReadFull(conn, l); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, int(l[0])<<8|int(l[1]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return checkResponse(query, resp)
+}
+
+//FakeResolver is an in-memory resolver, preloaded
+//with TXT, URI and CNAME records, intended for testing.
+//Names may use a leading "*." wildcard label.
+type FakeResolver struct {
+	mut    sync.RWMutex
+	txts   map[string][]string
+	uris   map[string][]*URI
+	cnames map[string]string
+}
+
+//NewFakeResolver creates an empty FakeResolver
+func NewFakeResolver() *FakeResolver {
+	return &FakeResolver{
+		txts:   map[string][]string{},
+		uris:   map[string][]*URI{},
+		cnames: map[string]string{},
+	}
+}
+
+//SetTXT replaces the TXT records of the given name
+func (f *FakeResolver) SetTXT(name string, txts ...string) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if len(txts) == 0 {
+		delete(f.txts, canonical(name))
+	} else {
+		f.txts[canonical(name)] = txts
+	}
+}
+
+//SetURI replaces the URI records of the given name
+func (f *FakeResolver) SetURI(name string, uris ...*URI) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if len(uris) == 0 {
+		delete(f.uris, canonical(name))
+	} else {
+		f.uris[canonical(name)] = uris
+	}
+}
+
+//SetCNAME replaces the CNAME record of the given name
+func (f *FakeResolver) SetCNAME(name, target string) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if target == "" {
+		delete(f.cnames, canonical(name))
+	} else {
+		f.cnames[canonical(name)] = canonical(target) + "."
+	}
+}
+
+//LookupTXT returns the TXT records of the given name
+func (f *FakeResolver) LookupTXT(name string) ([]string, error) {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	for _, n := range wildcards(name) {
+		if txts, ok := f.txts[n]; ok {
+			return append([]string{}, txts...), nil
+		}
+	}
+	return nil, notFound(name, "fake")
+}
+
+//LookupURI returns the URI records of the given name,
+//with a zero time-to-live so they are never cached
+func (f *FakeResolver) LookupURI(name string) ([]*URI, time.Duration, error) {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	for _, n := range wildcards(name) {
+		if uris, ok := f.uris[n]; ok {
+			return append([]*URI{}, uris...), 0, nil
+		}
+	}
+	return nil, 0, notFound(name, "fake")
+}
+
+//LookupCNAME returns the canonical name of the given name
+func (f *FakeResolver) LookupCNAME(name string) (string, error) {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	for _, n := range wildcards(name) {
+		if cname, ok := f.cnames[n]; ok {
+			return cname, nil
+		}
+	}
+	return "", notFound(name, "fake")
+}
+
+//=============
+
+//newQuery packs a query message, when dnssec is set the
+//query requests the signatures (RRSIGs) of the answers
+func newQuery(name string, qtype dnsmessage.Type, id uint16, dnssec bool) ([]byte, error) {
+	n, err := dnsmessage.NewName(canonical(name) + ".")
+	if err != nil {
+		return nil, err
+	}
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  n,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	if dnssec {
+		opt := dnsmessage.Resource{Body: &dnsmessage.OPTResource{}}
+		if err := opt.Header.SetEDNS0(4096, dnsmessage.RCodeSuccess, true); err != nil {
+			return nil, err
+		}
+		msg.Additionals = append(msg.Additionals, opt)
+	}
+	return msg.Pack()
+}
+
+//answerOwner returns the name owning the answers to a query
+//of the given name and type, the end of any CNAME chain in the
+//answers. Records of other names (e.g. replayed from another
+//query) do not answer the query.
+func answerOwner(msg *dnsmessage.Message, name string, qtype dnsmessage.Type) string {
+	owner := canonical(name) + "."
+	for i := 0; i < maxCNAMEChain; i++ {
+		next := ""
+		for _, a := range msg.Answers {
+			if !strings.EqualFold(a.Header.Name.String(), owner) {
+				continue
+			}
+			if a.Header.Type == qtype {
+				return owner
+			}
+			if c, ok := a.Body.(*dnsmessage.CNAMEResource); ok && next == "" {
+				next = strings.ToLower(c.CNAME.String())
+			}
+		}
+		if next == "" {
+			break
+		}
+		owner = next
+	}
+	return owner
+}
+
+func txtAnswers(msg *dnsmessage.Message, name, server string) ([]string, time.Duration, error) {
+	owner := answerOwner(msg, name, dnsmessage.TypeTXT)
+	txts := []string{}
+	ttl := uint32(0)
+	for _, a := range msg.Answers {
+		txt, ok := a.Body.(*dnsmessage.TXTResource)
+		if !ok || !strings.EqualFold(a.Header.Name.String(), owner) {
+			continue
+		}
+		//join multiple strings, as does net.LookupTXT
+		txts = append(txts, strings.Join(txt.TXT, ""))
+		if len(txts) == 1 || a.Header.TTL < ttl {
+			ttl = a.Header.TTL
+		}
+	}
+	if len(txts) == 0 {
+		return nil, 0, notFound(name, server)
+	}
+	return txts, time.Duration(ttl) * time.Second, nil
+}
+
+func cnameAnswer(msg *dnsmessage.Message, name, server string) (string, error) {
+	//no type ends the chain, it is followed to the final target
+	cname := answerOwner(msg, name, 0)
+	if cname == canonical(name)+"." {
+		return "", notFound(name, server)
+	}
+	return cname, nil
+}
+
+//checkResponse unpacks a response, which must have the ID
+//and the question of the given (packed) query
+func checkResponse(query, resp []byte) (*dnsmessage.Message, error) {
+	q := &dnsmessage.Message{}
+	if err := q.Unpack(query); err != nil {
+		return nil, err
+	}
+	msg := &dnsmessage.Message{}
+	if err := msg.Unpack(resp); err != nil {
+		return nil, err
+	}
+	if !msg.Response || msg.ID != q.ID || len(msg.Questions) != 1 ||
+		msg.Questions[0].Type != q.Questions[0].Type ||
+		msg.Questions[0].Class != q.Questions[0].Class ||
+		!strings.EqualFold(msg.Questions[0].Name.String(), q.Questions[0].Name.String()) {
+		return nil, errors.New("invalid DNS response")
+	}
+	return msg, nil
+}
+
+func checkRCode(msg *dnsmessage.Message, name, server string) (*dnsmessage.Message, error) {
+	switch msg.RCode {
+	case dnsmessage.RCodeSuccess:
+		return msg, nil
+	case dnsmessage.RCodeNameError:
+		//returned along with the message, whose
+		//authority section may prove the name is missing
+		return msg, notFound(name, server)
+	default:
+		return nil, &net.DNSError{Err: msg.RCode.String(), Name: name, Server: server}
+	}
+}
+
+func notFound(name, server string) error {
+	return &net.DNSError{Err: "no such host", Name: name, Server: server, IsNotFound: true}
+}
+
+func isNotFound(err error) bool {
+	de, ok := err.(*net.DNSError)
+	return ok && de.IsNotFound
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+//canonical lower-cases a name and removes its trailing dot
+func canonical(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+//wildcards returns the name followed
+//by its matching wildcard names
+func wildcards(name string) []string {
+	name = canonical(name)
+	names := []string{name}
+	labels := strings.Split(name, ".")
+	for i := 1; i < len(labels); i++ {
+		names = append(names, "*."+strings.Join(labels[i:], "."))
+	}
+	return names
+}
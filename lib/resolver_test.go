@@ -0,0 +1,236 @@
+package subfwd
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+//dnsHandler returns the responses sent for a query
+type dnsHandler func(q *dnsmessage.Message, network string) []*dnsmessage.Message
+
+//startDNS serves the handler over UDP and TCP on a
+//local port, returning the address of both listeners
+func startDNS(t *testing.T, h dnsHandler) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Skip("tcp port in use: ", err)
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		l.Close()
+	})
+	pack := func(m *dnsmessage.Message) []byte {
+		b, err := m.Pack()
+		if err != nil {
+			t.Error(err)
+		}
+		return b
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			q := &dnsmessage.Message{}
+			if q.Unpack(buf[:n]) != nil {
+				continue
+			}
+			for _, m := range h(q, "udp") {
+				pc.WriteTo(pack(m), addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				l := make([]byte, 2)
+				if _, err := io.ReadFull(conn, l); err != nil {
+					return
+				}
+				b := make([]byte, binary.BigEndian.Uint16(l))
+				if _, err := io.ReadFull(conn, b); err != nil {
+					return
+				}
+				q := &dnsmessage.Message{}
+				if q.Unpack(b) != nil {
+					return
+				}
+				for _, m := range h(q, "tcp") {
+					b := pack(m)
+					conn.Write(append([]byte{byte(len(b) >> 8), byte(len(b))}, b...))
+				}
+			}()
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+//reply creates the response to a query
+func reply(q *dnsmessage.Message, answers ...dnsmessage.Resource) *dnsmessage.Message {
+	return &dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.ID, Response: true},
+		Questions: q.Questions,
+		Answers:   answers,
+	}
+}
+
+func txtRR(name string, ttl uint32, txt ...string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.TXTResource{TXT: txt},
+	}
+}
+
+func cnameRR(name, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 60},
+		Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)},
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+	for _, c := range []struct {
+		addr, want string
+	}{
+		{"", "system"},
+		{"https://dns.example/dns-query", "doh https://dns.example/dns-query"},
+		{"1.1.1.1", "udp 1.1.1.1:53"},
+		{"udp://1.1.1.1:5353", "udp 1.1.1.1:5353"},
+		{"tcp://[::1]", "tcp [::1]:53"},
+	} {
+		r, err := NewResolver(c.addr)
+		if err != nil {
+			t.Fatalf("%q: %s", c.addr, err)
+		}
+		got := ""
+		switch r := r.(type) {
+		case *SystemResolver:
+			got = "system"
+		case *DoHResolver:
+			got = "doh " + r.URL
+		case *UpstreamResolver:
+			got = r.Net + " " + r.Addr
+		}
+		if got != c.want {
+			t.Errorf("%q: got %q, want %q", c.addr, got, c.want)
+		}
+	}
+	if _, err := NewResolver("1.1.1.1:53:53"); err == nil {
+		t.Error("expected an invalid address error")
+	}
+}
+
+func TestUpstreamResolver(t *testing.T) {
+	addr := startDNS(t, func(q *dnsmessage.Message, network string) []*dnsmessage.Message {
+		name := q.Questions[0].Name.String()
+		switch name {
+		case "subfwd-a.example.com.":
+			return []*dnsmessage.Message{reply(q, txtRR(name, 300, "http://", "a.com"), txtRR(name, 60, "v=spf1"))}
+		case "subfwd-long.example.com.":
+			//only answered in full over tcp
+			m := reply(q)
+			if network == "udp" {
+				m.Truncated = true
+			} else {
+				m.Answers = append(m.Answers, txtRR(name, 300, "http://long.com"))
+			}
+			return []*dnsmessage.Message{m}
+		case "www.example.com.":
+			return []*dnsmessage.Message{reply(q, cnameRR(name, "a.example.net."), cnameRR("a.example.net.", "b.example.net."))}
+		}
+		m := reply(q)
+		m.RCode = dnsmessage.RCodeNameError
+		return []*dnsmessage.Message{m}
+	})
+	u := NewUpstreamResolver(addr, "udp")
+	txts, ttl, err := u.LookupTXTTTL("subfwd-a.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(txts, ",") != "http://a.com,v=spf1" || ttl != time.Minute {
+		t.Errorf("got %v %s", txts, ttl)
+	}
+	if txts, err := u.LookupTXT("subfwd-long.example.com"); err != nil || len(txts) != 1 || txts[0] != "http://long.com" {
+		t.Errorf("truncated answer not retried over tcp: %v %v", txts, err)
+	}
+	if cname, err := u.LookupCNAME("www.example.com"); err != nil || cname != "b.example.net." {
+		t.Errorf("got cname %q %v", cname, err)
+	}
+	if _, err := u.LookupTXT("subfwd-b.example.com"); !isNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	u.Net = "tcp"
+	if txts, err := u.LookupTXT("subfwd-a.example.com"); err != nil || len(txts) != 2 {
+		t.Errorf("tcp: got %v %v", txts, err)
+	}
+}
+
+func TestUpstreamResolverMismatch(t *testing.T) {
+	addr := startDNS(t, func(q *dnsmessage.Message, network string) []*dnsmessage.Message {
+		name := q.Questions[0].Name.String()
+		//responses to other queries are sent first
+		badID := reply(q, txtRR(name, 60, "http://evil.com"))
+		badID.ID++
+		badName := reply(q, txtRR("subfwd-evil.example.com.", 60, "http://evil.com"))
+		badName.Questions = []dnsmessage.Question{{Name: dnsmessage.MustNewName("subfwd-evil.example.com."), Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET}}
+		badType := reply(q, txtRR(name, 60, "http://evil.com"))
+		badType.Questions = []dnsmessage.Question{{Name: q.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}}
+		if name == "subfwd-spoofed.example.com." {
+			return []*dnsmessage.Message{badID, badName, badType}
+		}
+		return []*dnsmessage.Message{badID, badName, badType, reply(q, txtRR(name, 60, "http://good.com"))}
+	})
+	u := NewUpstreamResolver(addr, "udp")
+	u.Timeout = 200 * time.Millisecond
+	if txts, err := u.LookupTXT("subfwd-a.example.com"); err != nil || len(txts) != 1 || txts[0] != "http://good.com" {
+		t.Errorf("got %v %v", txts, err)
+	}
+	if txts, err := u.LookupTXT("subfwd-spoofed.example.com"); err == nil || !isTimeout(err) {
+		t.Errorf("expected a timeout, got %v %v", txts, err)
+	}
+	u.Net = "tcp"
+	if txts, err := u.LookupTXT("subfwd-a.example.com"); err == nil {
+		t.Errorf("expected mismatched tcp response to fail, got %v", txts)
+	}
+}
+
+func TestFakeResolver(t *testing.T) {
+	f := NewFakeResolver()
+	f.SetTXT("subfwd-a.example.com", "http://a.com")
+	f.SetTXT("*.example.net", "http://wild.com")
+	f.SetCNAME("WWW.example.com.", "subfwd.herokuapp.com")
+	if txts, err := f.LookupTXT("subfwd-a.example.com."); err != nil || txts[0] != "http://a.com" {
+		t.Errorf("got %v %v", txts, err)
+	}
+	if txts, err := f.LookupTXT("subfwd-x.y.example.net"); err != nil || txts[0] != "http://wild.com" {
+		t.Errorf("wildcard: got %v %v", txts, err)
+	}
+	if _, err := f.LookupTXT("example.net"); !isNotFound(err) {
+		t.Errorf("wildcard matched its parent: %v", err)
+	}
+	if cname, err := f.LookupCNAME("www.example.com"); err != nil || cname != "subfwd.herokuapp.com." {
+		t.Errorf("got cname %q %v", cname, err)
+	}
+	f.SetTXT("subfwd-a.example.com")
+	if _, err := f.LookupTXT("subfwd-a.example.com"); !isNotFound(err) {
+		t.Errorf("expected removed record, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package subfwd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Route is a path based routing rule within a Rule,
+//written as "route=<pattern> <url>". Patterns without
+//wildcards match the path prefix (at a segment boundary).
+//Each "*" wildcard matches any text (including slashes)
+//and is captured, for use in the URL as $1, $2 and so on:
+//
+//	route=/gh/* https://github.com/$1
+type Route struct {
+	Pattern string
+	URL     string
+	re      *regexp.Regexp
+}
+
+var captureVars = regexp.MustCompile(`\$(\d)`)
+
+//maxRouteCache bounds the compiled patterns,
+//the cache is reset once full like exprCache
+const maxRouteCache = 1024
+
+var routeCache = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: map[string]*regexp.Regexp{}}
+
+//parseRoute parses "<pattern> <url>"
+func parseRoute(s string) (*Route, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid route '%s'", s)
+	}
+	r := &Route{Pattern: fields[0], URL: fields[1]}
+	if !strings.HasPrefix(r.Pattern, "/") {
+		return nil, fmt.Errorf("invalid route pattern '%s'", r.Pattern)
+	}
+	if err := checkURL(r.URL); err != nil {
+		return nil, err
+	}
+	if strings.Contains(r.Pattern, "*") {
+		r.re = compilePattern(r.Pattern)
+	}
+	return r, nil
+}
+
+//compilePattern compiles a wildcard pattern. Since rules
+//are parsed on every lookup, compiled patterns are cached.
+func compilePattern(pattern string) *regexp.Regexp {
+	routeCache.Lock()
+	defer routeCache.Unlock()
+	if re, ok := routeCache.m[pattern]; ok {
+		return re
+	}
+	expr := strings.Replace(regexp.QuoteMeta(pattern), `\*`, `(.*)`, -1)
+	re := regexp.MustCompile("^" + expr + "$")
+	if len(routeCache.m) >= maxRouteCache {
+		routeCache.m = map[string]*regexp.Regexp{}
+	}
+	routeCache.m[pattern] = re
+	return re
+}
+
+//match returns the URL of the route for the given (escaped)
+//path, and the remainder of the path after a prefix match
+func (r *Route) match(path string) (string, string, bool) {
+	if r.re == nil {
+		prefix := strings.TrimSuffix(r.Pattern, "/")
+		if path == prefix || path == prefix+"/" {
+			return r.URL, "", true
+		} else if strings.HasPrefix(path, prefix+"/") {
+			return r.URL, strings.TrimPrefix(path, prefix), true
+		}
+		return "", "", false
+	}
+	m := r.re.FindStringSubmatch(path)
+	if m == nil {
+		return "", "", false
+	}
+	url := captureVars.ReplaceAllStringFunc(r.URL, func(v string) string {
+		i, _ := strconv.Atoi(v[1:])
+		if i == 0 || i >= len(m) {
+			return ""
+		}
+		//captures are not templates
+		return strings.Replace(m[i], "$", "%24", -1)
+	})
+	return url, "", true
+}
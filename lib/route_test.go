@@ -0,0 +1,49 @@
+package subfwd
+
+import "testing"
+
+func TestRoutes(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-go.example.com", "v=subfwd1; url=http://home.com; route=/gh/* https://github.com/$1; route=/docs https://docs.com/base; route=/u/*/p/* https://x.com/$2/$1; path=pass")
+	for u, want := range map[string]string{
+		"http://go.example.com/gh/jpillora/subfwd": "https://github.com/jpillora/subfwd",
+		"http://go.example.com/docs/a/b?q=1":       "https://docs.com/base/a/b",
+		"http://go.example.com/docs":               "https://docs.com/base",
+		//prefixes match at a segment boundary
+		"http://go.example.com/docsx":      "http://home.com/docsx",
+		"http://go.example.com/u/bob/p/42": "https://x.com/42/bob",
+		"http://go.example.com/other":      "http://home.com/other",
+	} {
+		w := do(s, "GET", u)
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != want {
+			t.Errorf("%s: got %d %q, want %q", u, w.Code, loc, want)
+		}
+	}
+}
+
+func TestRouteErrors(t *testing.T) {
+	for s, want := range map[string]string{
+		"/a":                "invalid route '/a'",
+		"/a http://a.com x": "invalid route '/a http://a.com x'",
+		"a http://a.com":    "invalid route pattern 'a'",
+		"/a ftp://a.com":    "invalid url 'ftp://a.com'",
+	} {
+		if _, err := parseRoute(s); err == nil || err.Error() != want {
+			t.Errorf("%q: got %v, want %q", s, err, want)
+		}
+	}
+}
+
+func TestRouteCache(t *testing.T) {
+	a, err := parseRoute("/gh/* https://github.com/$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := parseRoute("/gh/* https://gitlab.com/$1")
+	if a.re == nil || a.re != b.re {
+		t.Error("expected the compiled pattern to be shared")
+	}
+	if url, _, ok := b.match("/gh/a/b"); !ok || url != "https://gitlab.com/a/b" {
+		t.Errorf("got %q %v", url, ok)
+	}
+}
@@ -0,0 +1,438 @@
+package subfwd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ruleVersion is the version tag of structured records
+const ruleVersion = "subfwd1"
+
+//fragmentPrefix is the continuation syntax used to split
+//a value across multiple TXT records, e.g. "[1/2]http://..."
+var fragmentPrefix = regexp.MustCompile(`^\[(\d+)/(\d+)\]`)
+
+//errNotRule is returned when a TXT
+//record is not a subfwd record at all
+var errNotRule = errors.New("not a subfwd record")
+
+//errNotYet and errExpired are returned when a rule
+//is used outside of its window without an alternate
+var errNotYet = errors.New("Not yet active")
+var errExpired = errors.New("Expired")
+
+//timeFormats are the accepted not_before/not_after formats
+var timeFormats = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"}
+
+//Rule is a parsed subfwd TXT record. Records are either
+//a bare URL (http://...) or, in the structured syntax, a
+//semi-colon separated list of key=value pairs starting
+//with the version tag, for example:
+//
+//	v=subfwd1; url=https://example.com; code=301; mode=proxy; path=pass; query=pass
+//
+//Values containing semi-colons must be double-quoted.
+type Rule struct {
+	//URL is the target URL (before substitution)
+	URL string
+	//Code is the redirect status code (0 uses the default)
+	Code int `json:",omitempty"`
+	//Mode is "forward" or "proxy" ("" uses the record prefix)
+	Mode string `json:",omitempty"`
+	//Path is "pass" or "drop" ("" uses the mode default)
+	Path string `json:",omitempty"`
+	//Query is "pass" (keeping the target's values on conflict),
+	//"override" or "drop" ("" uses the mode default)
+	Query string `json:",omitempty"`
+	//Routes are the path based routing rules
+	Routes []*Route `json:",omitempty"`
+	//Conditions are the request based routing rules
+	Conditions []*Condition `json:",omitempty"`
+	//Variants are the weighted targets of a split
+	Variants []*Variant `json:",omitempty"`
+	//NotBefore and NotAfter bound the active window (RFC3339
+	//or YYYY-MM-DD, in UTC unless zoned)
+	NotBefore *time.Time `json:",omitempty"`
+	NotAfter  *time.Time `json:",omitempty"`
+	//Before and After are the alternate targets outside the window
+	Before string `json:",omitempty"`
+	After  string `json:",omitempty"`
+	//Timeout and Idle bound proxied responses (nil uses the server default, 0 is unlimited)
+	Timeout *time.Duration `json:",omitempty"`
+	Idle    *time.Duration `json:",omitempty"`
+	//Forwarded and Host set the proxied request headers ("" uses the server default)
+	Forwarded string `json:",omitempty"`
+	Host      string `json:",omitempty"`
+}
+
+//ParseRule parses a single TXT record
+func ParseRule(txt string) (*Rule, error) {
+	txt = strings.TrimSpace(txt)
+	if strings.HasPrefix(txt, "http") {
+		if err := checkURL(txt); err != nil {
+			return nil, err
+		}
+		return &Rule{URL: txt}, nil
+	}
+	if strings.HasPrefix(txt, "w=") || strings.HasPrefix(txt, "url=") {
+		v, err := parseVariant(txt)
+		if err != nil {
+			return nil, err
+		}
+		return &Rule{Variants: []*Variant{v}}, nil
+	}
+	if !strings.HasPrefix(txt, "v=subfwd") {
+		return nil, errNotRule
+	}
+	pairs, err := splitPairs(txt)
+	if err != nil {
+		return nil, err
+	}
+	if pairs[0][0] != "v" || pairs[0][1] != ruleVersion {
+		return nil, fmt.Errorf("unsupported version '%s'", pairs[0][1])
+	}
+	rule := &Rule{}
+	for _, pair := range pairs[1:] {
+		if err := rule.set(pair[0], pair[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := rule.check(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+//set sets the given key of a structured rule,
+//repeatable keys (route, when, variant) are appended
+func (rule *Rule) set(k, v string) error {
+	switch k {
+	case "url":
+		if err := checkURL(v); err != nil {
+			return err
+		}
+		rule.URL = v
+	case "code":
+		code, err := strconv.Atoi(v)
+		if err != nil || !validCode(code) {
+			return fmt.Errorf("invalid code '%s'", v)
+		}
+		rule.Code = code
+	case "mode":
+		if v != "forward" && v != "proxy" {
+			return fmt.Errorf("invalid mode '%s'", v)
+		}
+		rule.Mode = v
+	case "path":
+		if v != "pass" && v != "drop" {
+			return fmt.Errorf("invalid path '%s'", v)
+		}
+		rule.Path = v
+	case "query":
+		if v != "pass" && v != "override" && v != "drop" {
+			return fmt.Errorf("invalid query '%s'", v)
+		}
+		rule.Query = v
+	case "not_before", "not_after":
+		t, err := parseTime(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s'", k, v)
+		}
+		if k == "not_before" {
+			rule.NotBefore = &t
+		} else {
+			rule.NotAfter = &t
+		}
+	case "before", "after":
+		if err := checkURL(v); err != nil {
+			return err
+		}
+		if k == "before" {
+			rule.Before = v
+		} else {
+			rule.After = v
+		}
+	case "timeout", "idle":
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid %s '%s'", k, v)
+		}
+		if k == "timeout" {
+			rule.Timeout = &d
+		} else {
+			rule.Idle = &d
+		}
+	case "forwarded":
+		if !forwardedPolicies[v] {
+			return fmt.Errorf("invalid forwarded '%s'", v)
+		}
+		rule.Forwarded = v
+	case "host":
+		if v != "preserve" && v != "target" {
+			return fmt.Errorf("invalid host '%s'", v)
+		}
+		rule.Host = v
+	case "when":
+		cond, err := parseCondition(v)
+		if err != nil {
+			return err
+		}
+		rule.Conditions = append(rule.Conditions, cond)
+	case "route":
+		route, err := parseRoute(v)
+		if err != nil {
+			return err
+		}
+		rule.Routes = append(rule.Routes, route)
+	case "variant":
+		variant, err := parseVariant(v)
+		if err != nil {
+			return err
+		}
+		rule.Variants = append(rule.Variants, variant)
+	default:
+		return fmt.Errorf("unknown key '%s'", k)
+	}
+	return nil
+}
+
+//check validates a structured rule once all keys are set
+func (rule *Rule) check() error {
+	if rule.URL == "" && len(rule.Variants) == 0 {
+		return errors.New("missing url")
+	}
+	if len(rule.Variants) > 0 {
+		if err := checkWeights(rule.Variants); err != nil {
+			return err
+		}
+	}
+	if rule.NotBefore != nil && rule.NotAfter != nil && !rule.NotBefore.Before(*rule.NotAfter) {
+		return errors.New("not_before must be before not_after")
+	}
+	return nil
+}
+
+//window returns the alternate target of the rule when the given
+//time is outside of its window, or an error when it has none
+func (rule *Rule) window(now time.Time) (string, error) {
+	if rule.NotBefore != nil && now.Before(*rule.NotBefore) {
+		if rule.Before == "" {
+			return "", errNotYet
+		}
+		return rule.Before, nil
+	}
+	if rule.NotAfter != nil && !now.Before(*rule.NotAfter) {
+		if rule.After == "" {
+			return "", errExpired
+		}
+		return rule.After, nil
+	}
+	return "", nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, f := range timeFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid time")
+}
+
+//target returns the URL of the first route matching the
+//given (escaped) path, or the rule's URL, along with the
+//remainder of the path which may be passed to the target
+func (rule *Rule) target(path string) (string, string) {
+	for _, route := range rule.Routes {
+		if url, rest, ok := route.match(path); ok {
+			return url, rest
+		}
+	}
+	return rule.URL, path
+}
+
+//condition returns the first condition matching the request
+func (rule *Rule) condition(r *http.Request, geo *GeoDB) *Condition {
+	for _, c := range rule.Conditions {
+		if c.expr.Match(r, geo) {
+			return c
+		}
+	}
+	return nil
+}
+
+//apply passes the remaining path and the query of the
+//incoming request onto the target, according to the rule
+func (rule *Rule) apply(target *url.URL, rest, rawQuery string, proxy bool) {
+	path, query := rule.Path, rule.Query
+	if path == "" {
+		path = "drop"
+		if proxy {
+			path = "pass"
+		}
+	}
+	if query == "" {
+		query = "drop"
+		if proxy {
+			query = "pass"
+		}
+	}
+	if path == "pass" {
+		joinPath(target, rest)
+	}
+	if query != "drop" && rawQuery != "" {
+		target.RawQuery = mergeQuery(target.RawQuery, rawQuery, query == "override")
+	}
+}
+
+//joinPath appends the (escaped) path p onto
+//target, preserving their escaping
+func joinPath(target *url.URL, p string) {
+	if p == "" || p == "/" {
+		return
+	}
+	joined := strings.TrimSuffix(target.EscapedPath(), "/") + "/" + strings.TrimPrefix(p, "/")
+	unescaped, err := url.PathUnescape(joined)
+	if err != nil {
+		return
+	}
+	target.Path = unescaped
+	target.RawPath = joined
+}
+
+//mergeQuery merges the incoming query into the target query,
+//on conflicting keys, the target values are kept unless override
+func mergeQuery(target, in string, override bool) string {
+	tq, _ := url.ParseQuery(target)
+	iq, _ := url.ParseQuery(in)
+	out := url.Values{}
+	for k, v := range tq {
+		if _, ok := iq[k]; ok && override {
+			continue
+		}
+		out[k] = v
+	}
+	for k, v := range iq {
+		if _, ok := tq[k]; ok && !override {
+			continue
+		}
+		out[k] = v
+	}
+	return out.Encode()
+}
+
+//splitPairs splits "k1=v1; k2=\"v;2\"" into key-value pairs
+func splitPairs(txt string) ([][2]string, error) {
+	pairs := [][2]string{}
+	for txt != "" {
+		eq := strings.Index(txt, "=")
+		if eq == -1 {
+			return nil, fmt.Errorf("missing '=' in '%s'", txt)
+		}
+		k := strings.ToLower(strings.TrimSpace(txt[:eq]))
+		txt = strings.TrimLeft(txt[eq+1:], " ")
+		v := ""
+		if strings.HasPrefix(txt, `"`) {
+			end := strings.Index(txt[1:], `"`)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated quote in '%s'", k)
+			}
+			v = txt[1 : end+1]
+			txt = strings.TrimSpace(txt[end+2:])
+			if txt != "" && !strings.HasPrefix(txt, ";") {
+				return nil, fmt.Errorf("expected ';' after '%s'", k)
+			}
+		} else if semi := strings.Index(txt, ";"); semi >= 0 {
+			v = strings.TrimSpace(txt[:semi])
+			txt = txt[semi:]
+		} else {
+			v = strings.TrimSpace(txt)
+			txt = ""
+		}
+		txt = strings.TrimSpace(strings.TrimPrefix(txt, ";"))
+		if k == "" {
+			return nil, errors.New("missing key")
+		}
+		pairs = append(pairs, [2]string{k, v})
+	}
+	return pairs, nil
+}
+
+//checkURL checks the given target URL and its template
+func checkURL(s string) error {
+	if !strings.HasPrefix(s, "http") {
+		return fmt.Errorf("invalid url '%s'", s)
+	}
+	pieces, err := parseTemplate(s)
+	if err != nil {
+		return fmt.Errorf("invalid url '%s': %s", s, err)
+	}
+	//check the url with its variables filled in
+	sample := ""
+	for _, p := range pieces {
+		if p.v == nil {
+			sample += p.text
+		} else {
+			sample += "x"
+		}
+	}
+	if _, err := url.Parse(sample); err != nil {
+		return fmt.Errorf("invalid url '%s'", s)
+	}
+	return nil
+}
+
+func validCode(code int) bool {
+	switch code {
+	case 301, 302, 303, 307, 308:
+		return true
+	}
+	return false
+}
+
+//joinFragments reassembles a value which was split across
+//multiple TXT records using the continuation syntax, since
+//records may arrive in any order. Strings within a single
+//record are already joined by the resolver. Only one value
+//per name may be split, all other records are left as-is.
+func joinFragments(txts []string) ([]string, error) {
+	others := []string{}
+	fragments := map[int]string{}
+	total := -1
+	var err error
+	for _, txt := range txts {
+		m := fragmentPrefix.FindStringSubmatch(txt)
+		if m == nil {
+			others = append(others, txt)
+			continue
+		}
+		i, _ := strconv.Atoi(m[1])
+		n, _ := strconv.Atoi(m[2])
+		if n == 0 || i == 0 || i > n || (total != -1 && n != total) {
+			err = fmt.Errorf("invalid fragment '%s'", m[0])
+		} else if _, ok := fragments[i]; ok {
+			err = fmt.Errorf("duplicate fragment '%s'", m[0])
+		}
+		total = n
+		fragments[i] = txt[len(m[0]):]
+	}
+	if total == -1 {
+		return others, nil
+	} else if err != nil {
+		return others, err
+	} else if len(fragments) != total {
+		return others, fmt.Errorf("incomplete record (found %d of %d fragments)", len(fragments), total)
+	}
+	value := ""
+	for i := 1; i <= total; i++ {
+		value += fragments[i]
+	}
+	//joined value takes precedence
+	return append([]string{value}, others...), nil
+}
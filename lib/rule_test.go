@@ -0,0 +1,223 @@
+package subfwd
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, c := range []struct {
+		txt  string
+		want Rule
+	}{
+		{"http://a.com/x", Rule{URL: "http://a.com/x"}},
+		{" https://a.com ", Rule{URL: "https://a.com"}},
+		{"v=subfwd1; url=http://a.com", Rule{URL: "http://a.com"}},
+		{"v=subfwd1;url=http://a.com;code=301;mode=proxy;path=pass;query=override", Rule{URL: "http://a.com", Code: 301, Mode: "proxy", Path: "pass", Query: "override"}},
+		{`v=subfwd1; url="http://a.com/x;y?a=1"; code=308`, Rule{URL: "http://a.com/x;y?a=1", Code: 308}},
+		{"v=subfwd1; URL=http://a.com; mode=forward", Rule{URL: "http://a.com", Mode: "forward"}},
+	} {
+		rule, err := ParseRule(c.txt)
+		if err != nil {
+			t.Errorf("%q: %s", c.txt, err)
+			continue
+		}
+		got, _ := json.Marshal(rule)
+		want, _ := json.Marshal(c.want)
+		if string(got) != string(want) {
+			t.Errorf("%q: got %s, want %s", c.txt, got, want)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for txt, want := range map[string]string{
+		"v=spf1 -all":                            errNotRule.Error(),
+		"google-site-verification=abc":           errNotRule.Error(),
+		"v=subfwd2; url=http://a.com":            "unsupported version 'subfwd2'",
+		"v=subfwd1; code=301":                    "missing url",
+		"v=subfwd1; url=ftp://a.com":             "invalid url 'ftp://a.com'",
+		"v=subfwd1; url=http://a.com; code=200":  "invalid code '200'",
+		"v=subfwd1; url=http://a.com; mode=x":    "invalid mode 'x'",
+		"v=subfwd1; url=http://a.com; path=x":    "invalid path 'x'",
+		"v=subfwd1; url=http://a.com; query=x":   "invalid query 'x'",
+		"v=subfwd1; url=http://a.com; bogus=1":   "unknown key 'bogus'",
+		"v=subfwd1; url=http://a.com; code":      "missing '=' in 'code'",
+		`v=subfwd1; url="http://a.com`:           "unterminated quote in 'url'",
+		`v=subfwd1; url="http://a.com" code=301`: "expected ';' after 'url'",
+		"v=subfwd1; url=http://a.com; =1":        "missing key",
+		"http://a.com/%zz":                       "invalid url 'http://a.com/%zz'",
+	} {
+		_, err := ParseRule(txt)
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: got error %v, want %q", txt, err, want)
+		}
+	}
+}
+
+func TestRuleRecords(t *testing.T) {
+	s, f := newTestServer(t, Config{AdminHosts: []string{"admin.test"}})
+	var logs []string
+	s.logf = func(format string, args ...interface{}) {
+		logs = append(logs, format)
+	}
+	f.SetTXT("subfwd-a.example.com", "v=spf1 -all", `v=subfwd1; url="http://a.com/x;y"; code=301`)
+	f.SetTXT("subfwd-b.example.com", "v=subfwd1; url=http://b.com; bogus=1", "http://fallback.com")
+	f.SetTXT("subfwd-c.example.com", "v=subfwd1; url=http://c.com; code=200")
+	w := do(s, "GET", "http://a.example.com/")
+	if w.Code != 301 || w.Header().Get("Location") != "http://a.com/x;y" {
+		t.Errorf("got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	//invalid records are skipped
+	w = do(s, "GET", "http://b.example.com/")
+	if w.Code != 302 || w.Header().Get("Location") != "http://fallback.com" {
+		t.Errorf("got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w = do(s, "GET", "http://c.example.com/"); w.Code != 404 {
+		t.Errorf("got %d", w.Code)
+	}
+	if len(logs) == 0 || !strings.HasPrefix(logs[0], "Invalid record") {
+		t.Errorf("expected invalid records to be logged, got %q", logs)
+	}
+	//and reported by the diagnostics endpoint
+	w = do(s, "GET", "http://admin.test/diagnose?host=b.example.com")
+	res := &resolution{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, rec := range res.Records {
+		if rec.Name == "subfwd-b.example.com" {
+			found = len(rec.Errors) == 1 && rec.Errors[0] == "unknown key 'bogus'" && rec.Rule.URL == "http://fallback.com"
+		}
+	}
+	if !found || res.Rule.URL != "http://fallback.com" {
+		t.Errorf("got %s", w.Body.String())
+	}
+	if w = do(s, "GET", "http://admin.test/diagnose"); w.Code != 400 {
+		t.Errorf("got %d", w.Code)
+	}
+}
+
+func TestJoinFragments(t *testing.T) {
+	a, b, c := strings.Repeat("a", 255), strings.Repeat("b", 255), strings.Repeat("c", 1)
+	for _, tc := range []struct {
+		txts []string
+		want []string
+		err  string
+	}{
+		{[]string{"http://a.com", "v=spf1"}, []string{"http://a.com", "v=spf1"}, ""},
+		{[]string{"[1/1]http://a.com"}, []string{"http://a.com"}, ""},
+		{[]string{"[1/2]http://", "v=spf1", "[2/2]a.com"}, []string{"http://a.com", "v=spf1"}, ""},
+		//out of order
+		{[]string{"[3/3]" + c, "[1/3]" + a, "[2/3]" + b}, []string{a + b + c}, ""},
+		{[]string{"[2/2]" + b, "v=spf1", "[1/2]" + a}, []string{a + b, "v=spf1"}, ""},
+		{[]string{"[10/10]j", "[2/10]b", "[1/10]a", "[3/10]c", "[4/10]d", "[5/10]e", "[6/10]f", "[7/10]g", "[8/10]h", "[9/10]i"}, []string{"abcdefghij"}, ""},
+		//missing
+		{[]string{"[1/3]" + a, "[3/3]" + c, "v=spf1"}, []string{"v=spf1"}, "incomplete record (found 2 of 3 fragments)"},
+		{[]string{"[2/2]" + b}, []string{}, "incomplete record (found 1 of 2 fragments)"},
+		//duplicate
+		{[]string{"[1/2]" + a, "[1/2]" + b, "[2/2]" + c}, []string{}, "duplicate fragment '[1/2]'"},
+		//mismatched totals
+		{[]string{"[1/2]" + a, "[2/3]" + b}, []string{}, "invalid fragment '[2/3]'"},
+		{[]string{"[1/2]" + a, "[2/2]" + b, "[3/3]" + c}, []string{}, "invalid fragment '[3/3]'"},
+		//out of range
+		{[]string{"[0/1]" + a}, []string{}, "invalid fragment '[0/1]'"},
+		{[]string{"[2/1]" + a}, []string{}, "invalid fragment '[2/1]'"},
+		{[]string{"[1/0]" + a, "v=spf1"}, []string{"v=spf1"}, "invalid fragment '[1/0]'"},
+	} {
+		got, err := joinFragments(tc.txts)
+		errs := ""
+		if err != nil {
+			errs = err.Error()
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") || errs != tc.err {
+			t.Errorf("%.40q: got %.40q %q, want %.40q %q", tc.txts, got, errs, tc.want, tc.err)
+		}
+	}
+}
+
+//TestLongRecords checks values around the 255 byte
+//limit of TXT strings, split within a record by the DNS
+//provider, or across records with the continuation syntax
+func TestLongRecords(t *testing.T) {
+	url := func(n int) string {
+		return "http://a.com/" + strings.Repeat("x", n-len("http://a.com/"))
+	}
+	records := map[string][]dnsmessage.Resource{}
+	for _, n := range []int{254, 255, 256, 510, 511} {
+		name := "subfwd-l" + strconv.Itoa(n) + ".example.com."
+		u, strs := url(n), []string{}
+		for rest := u; rest != ""; {
+			l := len(rest)
+			if l > 255 {
+				l = 255
+			}
+			strs = append(strs, rest[:l])
+			rest = rest[l:]
+		}
+		records[name] = []dnsmessage.Resource{txtRR(name, 60, strs...)}
+	}
+	//a 600 byte value in fragments over multiple records,
+	//the first also split into strings within its record
+	long := url(600)
+	first := "[1/2]" + long[:400]
+	records["subfwd-f.example.com."] = []dnsmessage.Resource{
+		txtRR("subfwd-f.example.com.", 60, "[2/2]"+long[400:]),
+		txtRR("subfwd-f.example.com.", 60, first[:255], first[255:]),
+	}
+	addr := startDNS(t, func(q *dnsmessage.Message, network string) []*dnsmessage.Message {
+		if rrs, ok := records[q.Questions[0].Name.String()]; ok {
+			return []*dnsmessage.Message{reply(q, rrs...)}
+		}
+		m := reply(q)
+		m.RCode = dnsmessage.RCodeNameError
+		return []*dnsmessage.Message{m}
+	})
+	s, _ := newTestServer(t, Config{Resolver: NewUpstreamResolver(addr, "tcp")})
+	for _, n := range []int{254, 255, 256, 510, 511} {
+		w := do(s, "GET", "http://l"+strconv.Itoa(n)+".example.com/")
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != url(n) {
+			t.Errorf("%d bytes: got %d %d bytes", n, w.Code, len(loc))
+		}
+	}
+	w := do(s, "GET", "http://f.example.com/")
+	if loc := w.Header().Get("Location"); w.Code != 302 || loc != long {
+		t.Errorf("fragments: got %d %d bytes", w.Code, len(loc))
+	}
+}
+
+func TestWindow(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-past.example.com", "v=subfwd1; url=http://x.com; not_after=2020-01-01")
+	f.SetTXT("subfwd-pasta.example.com", "v=subfwd1; url=http://x.com; not_after=2020-01-01T00:00:00Z; after=http://over.com")
+	f.SetTXT("subfwd-fut.example.com", "v=subfwd1; url=http://x.com; not_before=2099-01-01")
+	f.SetTXT("subfwd-futb.example.com", "v=subfwd1; url=http://x.com; not_before=2099-01-01T10:00; before=http://soon.com")
+	f.SetTXT("subfwd-now.example.com", "v=subfwd1; url=http://x.com; not_before=2020-01-01; not_after=2099-01-01")
+	f.SetTXT("subfwd-inv.example.com", "v=subfwd1; url=http://x.com; not_before=2099-01-01; not_after=2020-01-01")
+	for _, c := range []struct {
+		host, want string
+		code       int
+	}{
+		{"past", "Redirect failed [Expired]", 410},
+		{"pasta", "http://over.com", 302},
+		{"fut", "Redirect failed [Not yet active]", 404},
+		{"futb", "http://soon.com", 302},
+		{"now", "http://x.com", 302},
+		//invalid windows are skipped
+		{"inv", "Redirect failed [No TXT]", 404},
+	} {
+		w := do(s, "GET", "http://"+c.host+".example.com/")
+		got := w.Header().Get("Location")
+		if w.Code != 302 {
+			got = w.Body.String()
+		}
+		if w.Code != c.code || got != c.want {
+			t.Errorf("%s: got %d %q, want %d %q", c.host, w.Code, got, c.code, c.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package subfwd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+//rulesPollInterval is how often the rules
+//file is checked for changes
+const rulesPollInterval = 5 * time.Second
+
+//RuleFile is a rule source for domains without TXT records,
+//read from a YAML, JSON or TOML file (by its extension). Hosts
+//map to a rule, either a TXT record value or its structured
+//keys, where routes, when and variants are lists. Wildcard
+//hosts (*.example.com) are the default of their subdomains and
+//the top-level default applies to all hosts, for example:
+//
+//	hosts:
+//	  go.example.com: https://golang.org
+//	  docs.example.com:
+//	    url: https://wiki.example.com/docs
+//	    mode: proxy
+//	    routes:
+//	    - /api/* https://api.example.com/$1
+//	  "*.example.com": https://example.com
+//	default: https://example.org
+//
+//By default, only exact hosts take precedence over DNS records,
+//wildcards and the default apply when there are none (see
+//RulesOrder). The file is reloaded when it changes and on SIGHUP.
+type RuleFile struct {
+	Path  string
+	Logf  func(string, ...interface{})
+	file  *fileWatcher
+	mut   sync.RWMutex
+	rules map[string]*Rule
+}
+
+//ruleFileData is the structure of a rules file
+type ruleFileData struct {
+	Hosts   map[string]interface{} `json:"hosts" yaml:"hosts" toml:"hosts"`
+	Default interface{}            `json:"default" yaml:"default" toml:"default"`
+}
+
+//OpenRuleFile loads the given rules file and
+//watches it for changes in the background
+func OpenRuleFile(path string) (*RuleFile, error) {
+	f := &RuleFile{Path: path, Logf: func(string, ...interface{}) {}}
+	f.file = &fileWatcher{path: path, interval: rulesPollInterval, reload: f.reload}
+	if err := f.file.load(false); err != nil {
+		return nil, err
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go f.file.watch(hup, func(err error) {
+		f.Logf("Rules file reload failed: %s", err)
+	})
+	return f, nil
+}
+
+//Rule returns the rule of the given host, wildcard
+//host (*.example.com) or the default (*), if any
+func (f *RuleFile) Rule(host string) *Rule {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	return f.rules[canonical(host)]
+}
+
+//reload replaces the rules with those of the given file
+func (f *RuleFile) reload(b []byte) error {
+	rules, err := parseRuleFile(filepath.Ext(f.Path), b)
+	if err != nil {
+		return fmt.Errorf("invalid rules file %s: %s", f.Path, err)
+	}
+	f.mut.Lock()
+	reload := f.rules != nil
+	f.rules = rules
+	f.mut.Unlock()
+	if reload {
+		f.Logf("Reloaded rules file %s (%d rules)", f.Path, len(rules))
+	}
+	return nil
+}
+
+//parseRuleFile parses the rules of a file with the given extension
+func parseRuleFile(ext string, b []byte) (map[string]*Rule, error) {
+	data := ruleFileData{}
+	var err error
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &data)
+	case ".json":
+		err = json.Unmarshal(b, &data)
+	case ".toml":
+		_, err = toml.Decode(string(b), &data)
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules := map[string]*Rule{}
+	for host, v := range data.Hosts {
+		rule, err := fileRule(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", host, err)
+		}
+		rules[canonical(host)] = rule
+	}
+	if data.Default != nil {
+		rule, err := fileRule(data.Default)
+		if err != nil {
+			return nil, fmt.Errorf("default: %s", err)
+		}
+		rules["*"] = rule
+	}
+	return rules, nil
+}
+
+//fileRule converts a decoded rule, either a
+//TXT record value or a map of its keys
+func fileRule(v interface{}) (*Rule, error) {
+	keys := map[string]interface{}{}
+	switch v := v.(type) {
+	case string:
+		rule, err := ParseRule(v)
+		if err == errNotRule {
+			return nil, fmt.Errorf("invalid rule '%s'", v)
+		}
+		return rule, err
+	case map[string]interface{}:
+		keys = v
+	case map[interface{}]interface{}:
+		for k, x := range v {
+			keys[fmt.Sprint(k)] = x
+		}
+	default:
+		return nil, fmt.Errorf("invalid rule '%v'", v)
+	}
+	names := []string{}
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	rule := &Rule{}
+	for _, k := range names {
+		items := []interface{}{keys[k]}
+		if list, ok := keys[k].([]interface{}); ok {
+			items = list
+		}
+		//lists are named in the plural
+		key := strings.TrimSuffix(k, "s")
+		for _, item := range items {
+			s := fmt.Sprint(item)
+			if t, ok := item.(time.Time); ok {
+				s = t.Format(time.RFC3339)
+			}
+			if err := rule.set(key, s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := rule.check(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
@@ -0,0 +1,155 @@
+package subfwd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testRules = `hosts:
+  go.example.com: https://golang.org
+  docs.example.com:
+    url: https://wiki.example.com/docs
+    code: 301
+    routes:
+    - /api/* https://api.example.com/$1
+    when:
+    - "lang == 'fr' => https://fr.example.com"
+  "*.example.com": https://example.com/wild
+default: https://example.org
+`
+
+//writeRules writes a rules file with the given name
+func writeRules(t *testing.T, dir, name, rules string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRuleFile(t *testing.T) {
+	dir := t.TempDir()
+	yml := writeRules(t, dir, "rules.yaml", testRules)
+	s, f := newTestServer(t, Config{Rules: yml, RulesOrder: "file"})
+	f.SetTXT("subfwd-go.example.com", "http://dns.com")
+	f.SetTXT("subfwd-x.other.com", "http://dns-other.com")
+	f.SetTXT("subfwd-dns.example.com", "http://dns-only.com")
+	for u, want := range map[string]string{
+		//exact hosts take precedence over DNS records
+		"http://go.example.com/":         "https://golang.org",
+		"http://docs.example.com/api/v1": "https://api.example.com/v1",
+		"http://docs.example.com/":       "https://wiki.example.com/docs",
+		//wildcards and the default do not
+		"http://dns.example.com/": "http://dns-only.com",
+		"http://x.other.com/":     "http://dns-other.com",
+		"http://a.b.example.com/": "https://example.com/wild",
+		"http://y.other.com/":     "https://example.org",
+	} {
+		w := do(s, "GET", u)
+		if loc := w.Header().Get("Location"); loc != want {
+			t.Errorf("%s: got %d %q, want %q", u, w.Code, loc, want)
+		}
+	}
+	if w := do(s, "GET", "http://docs.example.com/"); w.Code != 301 {
+		t.Errorf("got %d", w.Code)
+	}
+	//with the dns order, all records take precedence
+	s, f = newTestServer(t, Config{Rules: yml, RulesOrder: "dns"})
+	f.SetTXT("subfwd-go.example.com", "http://dns.com")
+	if w := do(s, "GET", "http://go.example.com/"); w.Header().Get("Location") != "http://dns.com" {
+		t.Errorf("dns order: got %q", w.Header().Get("Location"))
+	}
+	if w := do(s, "GET", "http://y.example.com/"); w.Header().Get("Location") != "https://example.com/wild" {
+		t.Errorf("dns order fallback: got %q", w.Header().Get("Location"))
+	}
+	if _, err := New(Config{Rules: yml, RulesOrder: "bad", Resolver: f}); err == nil {
+		t.Error("expected an invalid order error")
+	}
+}
+
+func TestRuleFileRecords(t *testing.T) {
+	s, _ := newTestServer(t, Config{Rules: writeRules(t, t.TempDir(), "rules.yaml", testRules), RulesOrder: "only"})
+	res, err := s.resolve("a.b.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ""
+	for _, rec := range res.Records {
+		got += rec.Name + " "
+	}
+	if want := "a.b.example.com b.example.com *.b.example.com *.example.com * "; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRuleFileFormats(t *testing.T) {
+	dir := t.TempDir()
+	js := writeRules(t, dir, "rules.json", `{"hosts":{"a.example.com":{"url":"https://json.com","mode":"proxy"}}}`)
+	s, _ := newTestServer(t, Config{Rules: js, RulesOrder: "only"})
+	if res, _ := s.resolve("a.example.com"); res.Rule == nil || res.Rule.URL != "https://json.com" || !res.Proxy {
+		t.Errorf("json: got %+v", res)
+	}
+	tm := writeRules(t, dir, "rules.toml", "default = \"https://toml.com\"\n[hosts]\n\"b.example.com\" = { url = \"https://tb.com\", not_after = 2000-01-01T00:00:00Z }\n")
+	s, _ = newTestServer(t, Config{Rules: tm})
+	if w := do(s, "GET", "http://b.example.com/"); w.Code != 410 {
+		t.Errorf("toml: got %d %s", w.Code, w.Body)
+	}
+	for name, rules := range map[string]string{
+		"x.ini":      "",
+		"bad.yaml":   "hosts: [",
+		"rule.yaml":  "hosts:\n  a.example.com: v=spf1",
+		"code.yaml":  "hosts:\n  a.example.com: {url: 'https://a.com', code: 200}",
+		"empty.json": `{"default": 1}`,
+	} {
+		if _, err := OpenRuleFile(writeRules(t, dir, name, rules)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRuleFileReload(t *testing.T) {
+	dir := t.TempDir()
+	js := writeRules(t, dir, "rules.json", `{"hosts":{"a.example.com":"https://json.com"}}`)
+	f, err := OpenRuleFile(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	touch := func(age time.Duration) {
+		mod := now.Add(age)
+		if err := os.Chtimes(js, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRules(t, dir, "rules.json", `{"hosts":{"a.example.com":"https://json2.com"}}`)
+	touch(time.Minute)
+	if err := f.file.load(false); err != nil {
+		t.Fatal(err)
+	}
+	if r := f.Rule("A.example.com."); r == nil || r.URL != "https://json2.com" {
+		t.Errorf("got %+v", r)
+	}
+	//unchanged files are only reloaded when forced
+	writeRules(t, dir, "rules.json", `{"hosts":{"a.example.com":"https://json3.com"}}`)
+	touch(time.Minute)
+	f.file.load(false)
+	if r := f.Rule("a.example.com"); r == nil || r.URL != "https://json2.com" {
+		t.Errorf("got %+v", r)
+	}
+	f.file.load(true)
+	if r := f.Rule("a.example.com"); r == nil || r.URL != "https://json3.com" {
+		t.Errorf("forced: got %+v", r)
+	}
+	//invalid files are not loaded
+	writeRules(t, dir, "rules.json", `{`)
+	touch(2 * time.Minute)
+	if err := f.file.load(false); err == nil {
+		t.Error("expected an invalid file error")
+	}
+	if r := f.Rule("a.example.com"); r == nil || r.URL != "https://json3.com" {
+		t.Errorf("got %+v", r)
+	}
+}
@@ -2,95 +2,363 @@ package subfwd
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
-	"sync"
 
 	ga "github.com/jpillora/go-ogle-analytics"
 	"github.com/jpillora/go-tld"
 	"github.com/jpillora/subfwd/lib/heroku"
 	"github.com/jpillora/subfwd/static"
-	"github.com/tomasen/realip"
 
 	"log"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
 const appName = "subfwd"
-const appDomain = appName + ".jpillora.com"
+
+//Config is the Subfwd configuration
+type Config struct {
+	AppDomain          string        `help:"domain serving the admin UI, also an accepted wildcard CNAME target" env:"APP_DOMAIN"`
+	AdminHosts         []string      `type:"commalist" help:"other hosts (host[:port]) serving the admin UI" env:"ADMIN_HOSTS"`
+	CNAMEs             []string      `name:"cnames" type:"commalist" help:"other accepted wildcard CNAME targets during setup" env:"CNAMES"`
+	Prefix             string        `help:"record prefix of forwards, e.g. <prefix>-<sub>.<domain>" env:"PREFIX"`
+	ProxyPrefix        string        `help:"record prefix of proxies, e.g. <proxy-prefix>-<sub>.<domain>" env:"PROXY_PREFIX"`
+	Redirect           int           `help:"default redirect status code (301, 302, 303, 307 or 308)" env:"REDIRECT_CODE"`
+	Naming             string        `help:"record naming scheme: legacy (subfwd-<sub>), underscore (_subfwd.<sub>) or both (underscore, falling back to legacy)" env:"NAMING"`
+	DNS                string        `help:"upstream DNS server address (host[:port], prefix with tcp:// to use TCP, or an https:// DNS-over-HTTPS URL), defaults to the system resolver, which looks up URI records from the first nameserver of /etc/resolv.conf" env:"DNS_SERVER"`
+	Zones              []string      `type:"commalist" help:"BIND-style zone files (TXT, CNAME and URI records) to use instead of DNS, for development and testing" env:"ZONE_FILES"`
+	DoHPost            bool          `name:"doh-post" help:"send DNS-over-HTTPS queries with POST instead of GET"`
+	DNSSEC             string        `help:"DNSSEC validation of TXT records: off, validate (reject bogus records) or require (also reject unsigned records), requires --dns"`
+	SecureZones        []string      `type:"commalist" help:"domains which must have DNSSEC signed TXT records (when validating)"`
+	Anchors            []string      `type:"commalist" help:"DNSSEC trust anchors as DS records '<zone> <key tag> <algorithm> <digest type> <digest>', defaults to the root zone keys"`
+	CacheSize          int           `help:"maximum number of cached TXT records (0 disables the cache)" env:"CACHE_SIZE"`
+	CacheTTL           time.Duration `help:"cache period for TXT records when the resolver does not report a TTL"`
+	NegativeTTL        time.Duration `help:"cache period for missing TXT records"`
+	StaleTTL           time.Duration `help:"grace period in which expired TXT records are served while the resolver is failing"`
+	Rules              string        `help:"rules file (YAML, JSON or TOML) mapping hosts to rules, reloaded when changed or on SIGHUP" env:"RULES_FILE"`
+	RulesOrder         string        `help:"precedence of the rules file: file (hosts before DNS, wildcards and default after), dns (after DNS) or only (no DNS lookups)" env:"RULES_ORDER"`
+	NSListen           string        `help:"address (host:port) of the embedded authoritative DNS server, over UDP and TCP, e.g. :53" env:"NS_LISTEN"`
+	NSZones            []string      `type:"commalist" help:"zones delegated to the embedded DNS server, e.g. links.example.com" env:"NS_ZONES"`
+	NSAddrs            []string      `type:"commalist" help:"IP addresses of this server, answered (A and AAAA) for all names within the zones" env:"NS_ADDRS"`
+	NSRecords          string        `help:"JSON file storing the TXT records of the embedded DNS server, managed via /records" env:"NS_RECORDS"`
+	AdminToken         string        `help:"bearer token required by the /records API" env:"ADMIN_TOKEN"`
+	ReadHeaderTimeout  time.Duration `help:"timeout reading request headers"`
+	ReadTimeout        time.Duration `help:"timeout reading requests, proxied requests use the stream timeout instead"`
+	WriteTimeout       time.Duration `help:"timeout writing responses, proxied responses use the stream timeouts instead"`
+	IdleTimeout        time.Duration `help:"period after which idle client connections are closed"`
+	StreamTimeout      time.Duration `help:"maximum duration of proxied responses and upgraded (WebSocket) connections, 0 is unlimited, overridden by the timeout of a record"`
+	StreamIdle         time.Duration `help:"period without data after which proxied responses and upgraded connections are closed, 0 is unlimited, overridden by the idle of a record"`
+	FlushInterval      time.Duration `help:"interval between flushes of proxied responses, -1ns flushes every write (event streams are always flushed immediately)"`
+	TrustedProxies     []string      `type:"commalist" help:"CIDRs of proxies in front of subfwd, whose forwarded headers (X-Forwarded-*, Forwarded and X-Real-IP) are trusted, they are removed from other requests (on Heroku, the router's private network 10.0.0.0/8 is also trusted)" env:"TRUSTED_PROXIES"`
+	Forwarded          string        `help:"forwarded headers sent to proxied targets: xff (X-Forwarded-For), x-forwarded (X-Forwarded-For, -Host and -Proto), rfc7239 (Forwarded), all or none, overridden by the forwarded of a record"`
+	PreserveHost       bool          `help:"send the original Host header to proxied targets instead of the target's host, overridden by the host of a record"`
+	ProxyIdleConns     int           `help:"maximum idle connections kept per proxied target host"`
+	ProxyIdleTimeout   time.Duration `help:"period after which idle proxy connections are closed"`
+	ProxyDialTimeout   time.Duration `help:"timeout connecting to proxied targets"`
+	ProxyTLSTimeout    time.Duration `help:"timeout of TLS handshakes with proxied targets"`
+	ProxyHeaderTimeout time.Duration `help:"timeout waiting for the response headers of proxied targets"`
+	ProxyHTTP2         bool          `name:"proxy-http2" help:"use HTTP/2 with proxied targets which support it, disable with --proxy-http2=false"`
+	GeoDB              string        `help:"MaxMind format (mmdb) IP database file for routing by country or continent, reloaded when changed" env:"GEO_DB"`
+	Sticky             time.Duration `help:"period in which visitors keep their A/B split variant using a cookie (0 disables)"`
+	Resolver           Resolver      `opts:"-"`
+}
 
 //Subfwd is an HTTP server
 type Subfwd struct {
-	server     *http.Server
-	fileserver http.Handler
-	onHeroku   bool
-	tracker    *ga.Client
-	logf       func(string, ...interface{})
-	stats      struct {
-		Heroku  bool
-		Uptime  string
-		Success uint
+	server         *http.Server
+	fileserver     http.Handler
+	appDomain      string
+	adminHosts     []string
+	cnames         []string
+	prefix         string
+	proxyPrefix    string
+	resolver       Resolver
+	lookupURIs     bool
+	naming         string
+	redirectCode   int
+	sticky         time.Duration
+	geo            *GeoDB
+	rules          *RuleFile
+	rulesOrder     string
+	cache          *Cache
+	ns             *NameServer
+	proxies        *proxyPool
+	streamTimeout  time.Duration
+	streamIdle     time.Duration
+	trustedProxies []*net.IPNet
+	forwarded      string
+	preserveHost   bool
+	nsListen       string
+	adminToken     string
+	onHeroku       bool
+	tracker        *ga.Client
+	logf           func(string, ...interface{})
+	mut            sync.Mutex
+	stats          struct {
+		Heroku      bool
+		Uptime      string
+		Success     uint
+		Cache       CacheStats
+		Variants    map[string]map[string]uint
+		AppDomain   string
+		Prefix      string
+		ProxyPrefix string
+		Redirect    int
 	}
 }
 
 //New creates a new sandbox
-func New() *Subfwd {
+func New(c Config) (*Subfwd, error) {
 	s := &Subfwd{}
+	s.appDomain = c.AppDomain
+	if s.appDomain == "" {
+		s.appDomain = appName + ".jpillora.com"
+	}
+	s.adminHosts = append([]string{s.appDomain}, c.AdminHosts...)
+	s.cnames = append([]string{s.appDomain}, c.CNAMEs...)
+	s.prefix = c.Prefix
+	if s.prefix == "" {
+		s.prefix = appName
+	}
+	s.proxyPrefix = c.ProxyPrefix
+	if s.proxyPrefix == "" {
+		s.proxyPrefix = "subproxy"
+	}
+	if s.prefix == s.proxyPrefix {
+		return nil, errors.New("prefix and proxy prefix must differ")
+	}
+	s.redirectCode = c.Redirect
+	if s.redirectCode == 0 {
+		s.redirectCode = 302
+	} else if !validCode(s.redirectCode) {
+		return nil, fmt.Errorf("invalid redirect code: %d", s.redirectCode)
+	}
+	switch c.Naming {
+	case "", "legacy":
+		s.naming = "legacy"
+	case "underscore", "both":
+		s.naming = c.Naming
+	default:
+		return nil, errors.New("invalid naming scheme: " + c.Naming)
+	}
+	s.resolver = c.Resolver
+	if s.resolver == nil && len(c.Zones) > 0 {
+		if c.DNS != "" {
+			return nil, errors.New("zone files and a DNS server are exclusive")
+		}
+		z, err := NewZoneResolver(c.Zones...)
+		if err != nil {
+			return nil, err
+		}
+		s.resolver = z
+	}
+	if s.resolver == nil {
+		r, err := NewResolver(c.DNS)
+		if err != nil {
+			return nil, err
+		}
+		if d, ok := r.(*DoHResolver); ok && c.DoHPost {
+			d.Method = "POST"
+		}
+		s.resolver = r
+	}
+	//wrapping resolvers support URIs when their base does
+	_, s.lookupURIs = s.resolver.(URIResolver)
+	sr, system := s.resolver.(*SystemResolver)
+	if system && sr.Upstream == nil {
+		s.lookupURIs = false
+	}
+	switch c.DNSSEC {
+	case "", "off":
+	case "validate", "require":
+		anchors := c.Anchors
+		if len(anchors) == 0 {
+			anchors = DefaultTrustAnchors
+		}
+		v, err := NewValidator(s.resolver, anchors)
+		if err != nil {
+			return nil, err
+		}
+		v.Require = c.DNSSEC == "require"
+		v.Domains = c.SecureZones
+		s.resolver = v
+	default:
+		return nil, errors.New("invalid DNSSEC mode: " + c.DNSSEC)
+	}
+	if c.CacheSize > 0 {
+		s.cache = NewCache(s.resolver, c.CacheSize)
+		s.cache.DefaultTTL = c.CacheTTL
+		s.cache.NegativeTTL = c.NegativeTTL
+		s.cache.StaleTTL = c.StaleTTL
+		s.resolver = s.cache
+	}
+	if len(c.NSZones) > 0 {
+		ns, err := NewNameServer(c.NSZones, c.NSAddrs, c.NSRecords)
+		if err != nil {
+			return nil, err
+		}
+		//records of the zones are always current
+		ns.Next = s.resolver
+		s.ns = ns
+		s.resolver = ns
+	} else if c.NSListen != "" {
+		return nil, errors.New("name server requires a zone")
+	}
+	s.nsListen = c.NSListen
+	s.proxies = newProxyPool(c)
 	s.onHeroku = heroku.ValidCreds()
+	proxies := c.TrustedProxies
+	//on Heroku (or any dyno) every request is forwarded by the router
+	dyno := s.onHeroku || os.Getenv("DYNO") != ""
+	if dyno {
+		proxies = append(proxies[:len(proxies):len(proxies)], herokuRouter)
+	}
+	trusted, err := parseCIDRs(proxies)
+	if err != nil {
+		return nil, err
+	}
+	s.trustedProxies = trusted
+	s.forwarded = c.Forwarded
+	if s.forwarded == "" {
+		s.forwarded = "x-forwarded"
+	} else if !forwardedPolicies[s.forwarded] {
+		return nil, errors.New("invalid forwarded policy: " + c.Forwarded)
+	}
+	s.preserveHost = c.PreserveHost
+	s.streamTimeout = c.StreamTimeout
+	s.streamIdle = c.StreamIdle
+	s.server = &http.Server{
+		Handler:           http.HandlerFunc(s.route),
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+		MaxHeaderBytes:    1 << 20,
+	}
+	s.adminToken = c.AdminToken
 	s.tracker, _ = ga.NewClient(os.Getenv("GA_TRACKER_ID"))
 	s.fileserver = static.Handler()
+	s.sticky = c.Sticky
+	if c.Rules != "" {
+		switch c.RulesOrder {
+		case "", "file":
+			s.rulesOrder = "file"
+		case "dns", "only":
+			s.rulesOrder = c.RulesOrder
+		default:
+			return nil, errors.New("invalid rules order: " + c.RulesOrder)
+		}
+		f, err := OpenRuleFile(c.Rules)
+		if err != nil {
+			return nil, err
+		}
+		s.rules = f
+	}
+	if c.GeoDB != "" {
+		g, err := OpenGeoDB(c.GeoDB)
+		if err != nil {
+			return nil, err
+		}
+		s.geo = g
+	}
 	s.stats.Heroku = s.onHeroku
+	s.stats.Variants = map[string]map[string]uint{}
+	s.stats.AppDomain = s.appDomain
+	s.stats.Prefix = s.prefix
+	s.stats.ProxyPrefix = s.proxyPrefix
+	s.stats.Redirect = s.redirectCode
 	s.stats.Uptime = time.Now().UTC().Format(time.RFC822)
 	s.logf = log.New(os.Stdout, appName+": ", 0).Printf //log.LstdFlags
-	return s
+	if s.cache != nil {
+		s.cache.Logf = s.logf
+	}
+	if s.geo != nil {
+		s.geo.Logf = s.logf
+	}
+	if s.rules != nil {
+		s.rules.Logf = s.logf
+	}
+	if s.ns != nil {
+		s.ns.Logf = s.logf
+	}
+	s.proxies.Logf = s.logf
+	if system && sr.Upstream == nil {
+		s.logf("Warning: no nameserver found in %s, URI records will not be looked up (see --dns)", resolvConf)
+	}
+	if dyno {
+		s.logf("Trusting the forwarded headers of the Heroku router (%s)", herokuRouter)
+	} else if loopbackOnly(s.trustedProxies) {
+		s.logf("Warning: only loopback proxies are trusted, the forwarded headers of other proxies are removed and their address is the client IP (see --trusted-proxies)")
+	}
+	return s, nil
 }
 
 //ListenAndServe and sandbox API and frontend
 func (s *Subfwd) ListenAndServe(port string) error {
-	server := &http.Server{
-		Addr:           ":" + port,
-		Handler:        http.HandlerFunc(s.route),
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
+	s.server.Addr = ":" + port
+
+	if s.ns != nil && s.nsListen != "" {
+		if err := s.ns.Listen(s.nsListen); err != nil {
+			return err
+		}
+		s.logf("Serving DNS for %s at %s", strings.Join(s.ns.Zones, ", "), s.nsListen)
 	}
 
 	s.logf("Listening at %s...", port)
-	if port == "3000" {
-		s.logf("View locally at http://abc.example.com:3000")
+	for _, host := range s.adminHosts {
+		if strings.HasSuffix(host, ":"+port) {
+			s.logf("View locally at http://%s", host)
+		}
 	}
 
-	return server.ListenAndServe()
+	return s.server.ListenAndServe()
 }
 
 //route request
 func (s *Subfwd) route(w http.ResponseWriter, r *http.Request) {
 	log.Println(r.Host)
+	r = s.trust(r)
 	if r.URL.Path == "/favicon.ico" {
 		w.WriteHeader(404)
-	} else if r.Host == appDomain || r.Host == "abc.example.com:3000" {
+	} else if s.isAdmin(r.Host) {
 		s.admin(w, r)
 	} else {
 		s.execute(w, r)
 	}
 }
 
+//isAdmin returns whether the given host serves the admin UI
+func (s *Subfwd) isAdmin(host string) bool {
+	for _, h := range s.adminHosts {
+		if strings.EqualFold(host, h) {
+			return true
+		}
+	}
+	return false
+}
+
 //admin request
 func (s *Subfwd) admin(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/stats" {
 		//show stats
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(200)
+		s.mut.Lock()
+		if s.cache != nil {
+			s.stats.Cache = s.cache.Stats()
+		}
 		b, _ := json.Marshal(s.stats)
+		s.mut.Unlock()
 		w.Write(b)
 	} else if r.URL.Path == "/headers" {
 		//echo request
@@ -98,6 +366,30 @@ func (s *Subfwd) admin(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 		b, _ := json.Marshal(r.Header)
 		w.Write(b)
+	} else if r.URL.Path == "/diagnose" {
+		//show how a host is resolved
+		host := r.URL.Query().Get("host")
+		if host == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("Missing host"))
+			return
+		}
+		res, err := s.resolve(host)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		b, _ := json.MarshalIndent(res, "", "  ")
+		w.Write(b)
+	} else if r.URL.Path == "/test" {
+		//evaluate a rule against a synthetic request
+		s.test(w, r)
+	} else if r.URL.Path == "/records" {
+		//manage the records of the name server
+		s.records(w, r)
 	} else if r.URL.Path == "/setup" {
 		//perform setup check on domain
 		err := s.setup(r.URL.Query().Get("domain"))
@@ -130,12 +422,12 @@ func (s *Subfwd) setup(domain string) error {
 	}
 
 	//check wildcard CNAME
-	cname, err := net.LookupCNAME(randHex() + "." + domain)
+	cname, err := s.resolver.LookupCNAME(randHex() + "." + domain)
 	if err != nil {
 		return errors.New("NO_CNAME")
 	}
 	cname = strings.TrimSuffix(cname, ".")
-	if cname != "subfwd.herokuapp.com" && cname != appDomain {
+	if !s.validCNAME(cname) {
 		s.logf("WRONG_CNAME: %s", cname)
 		return errors.New("WRONG_CNAME")
 	}
@@ -151,61 +443,38 @@ func (s *Subfwd) setup(domain string) error {
 	return nil
 }
 
+//validCNAME returns whether the given
+//CNAME target points at this server
+func (s *Subfwd) validCNAME(cname string) bool {
+	for _, c := range s.cnames {
+		if strings.EqualFold(cname, strings.TrimSuffix(c, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
 //execute request
 func (s *Subfwd) execute(w http.ResponseWriter, r *http.Request) {
-	u, err := tld.Parse("http://" + r.Host)
+	res, err := s.resolve(r.Host)
 	if err != nil {
 		s.logf("URL parse failed on %s (%s)", r.Host, err)
 		w.WriteHeader(500)
 		w.Write([]byte("This shouldn't happen..."))
 		return
 	}
-	//the domain is the host without the port
-	domain := u.Domain + "." + u.TLD
-	if domain == "lvh.me" {
-		domain = "jpillora.com" //debug swap (local dns is too hard - use live records)
-	}
-	subdomain := u.Subdomain + "." + domain
-
-	//lookup 3 txt entries in parallel
-	wg := &sync.WaitGroup{}
-	wg.Add(3)
-	lookup := func(domain string, result *url.URL) {
-		defer wg.Done()
-		txts, err := net.LookupTXT(domain)
-		if err != nil {
-			return
-		}
-		for _, txt := range txts {
-			if strings.HasPrefix(txt, "http") {
-				txt := substitiute(txt, r)
-				u, err := url.Parse(txt)
-				if err != nil {
-					s.logf("Invalid URL '%s'", u)
-					continue
-				}
-				*result = *u
-				return
-			}
+	subdomain := res.Subdomain
+	//refuse to guess when any record is bogus
+	if verr := res.bogus(); verr != nil {
+		s.logf("%s", verr)
+		if s.tracker != nil {
+			go s.tracker.Send(ga.NewEvent("Fail - DNSSEC", subdomain))
 		}
+		w.WriteHeader(502)
+		w.Write([]byte("Redirect failed [DNSSEC validation failed]"))
+		return
 	}
-
-	var forward, proxy, def url.URL
-	go lookup("subfwd-"+u.Subdomain+"."+domain, &forward)
-	go lookup("subproxy-"+u.Subdomain+"."+domain, &proxy)
-	go lookup("subfwd-default."+domain, &def)
-	wg.Wait()
-	//find target url
-	redirect := true
-	var target *url.URL
-	if proxy.String() != "" {
-		redirect = false
-		target = &proxy
-	} else if forward.String() != "" {
-		target = &forward
-	} else if def.String() != "" {
-		target = &def
-	} else {
+	if res.Rule == nil {
 		s.logf("No TXT set for: %s", subdomain)
 		if s.tracker != nil {
 			go s.tracker.Send(ga.NewEvent("Fail - No TXT", subdomain))
@@ -214,49 +483,276 @@ func (s *Subfwd) execute(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Redirect failed [No TXT]"))
 		return
 	}
+	//find target url
+	f, err := s.forward(r, res, time.Now())
+	if err != nil {
+		fail := err.(*failure)
+		s.logf("%s for: %s", fail.reason, subdomain)
+		if s.tracker != nil {
+			go s.tracker.Send(ga.NewEvent("Fail - "+fail.reason, subdomain))
+		}
+		w.WriteHeader(fail.status)
+		w.Write([]byte("Redirect failed [" + fail.reason + "]"))
+		return
+	}
+	if f.Variant != nil {
+		s.useVariant(w, r, res, f.Variant)
+	}
+	target := f.target
+	redirect := !f.Proxy
 	//log
 	action := "Redirect"
 	if !redirect {
 		action = "Proxy"
 	}
+	s.mut.Lock()
 	s.stats.Success++
-	log.Printf("#%05d [Success - %s] %s -> %s (from %s)", s.stats.Success, action, subdomain, target,
-		strings.TrimSpace(realip.RealIP(r)+" "+r.Header.Get("Referer")))
+	n := s.stats.Success
+	s.mut.Unlock()
+	log.Printf("#%05d [Success - %s] %s -> %s (from %s)", n, action, subdomain, target,
+		strings.TrimSpace(clientIP(r)+" "+r.Header.Get("Referer")))
 	if s.tracker != nil {
 		go s.tracker.Send(ga.NewEvent("Success - "+action, subdomain).Label(target.String()))
 	}
 	//perform
 	if redirect {
-		http.Redirect(w, r, target.String(), 302)
+		http.Redirect(w, r, target.String(), res.Code)
+	} else {
+		s.proxies.serve(w, r, subdomain, f)
+	}
+}
+
+//forward is the outcome of a request with a rule
+type forward struct {
+	Target    string
+	Proxy     bool
+	Code      int           `json:",omitempty"`
+	Condition string        `json:",omitempty"`
+	Variant   *Variant      `json:",omitempty"`
+	Timeout   time.Duration `json:",omitempty"`
+	Idle      time.Duration `json:",omitempty"`
+	Forwarded string        `json:",omitempty"`
+	KeepHost  bool          `json:",omitempty"`
+	target    *url.URL
+}
+
+//failure is a request which cannot be forwarded
+type failure struct {
+	status int
+	reason string
+}
+
+func (f *failure) Error() string {
+	return f.reason
+}
+
+//forward finds the target of the given request at the given
+//time. In order of precedence, the target is: the alternate
+//target outside of the rule's window, the first matching
+//condition, the first matching route, or the rule's URL
+//(or one of its variants).
+func (s *Subfwd) forward(r *http.Request, res *resolution, now time.Time) (*forward, error) {
+	rule := res.Rule
+	f := &forward{Proxy: res.Proxy}
+	if !f.Proxy {
+		f.Code = res.Code
 	} else {
-		p := httputil.NewSingleHostReverseProxy(target)
-		r.Host = target.Host //fix hostname
-		p.ServeHTTP(w, r)
+		f.Timeout, f.Idle = s.streamTimeout, s.streamIdle
+		if rule.Timeout != nil {
+			f.Timeout = *rule.Timeout
+		}
+		if rule.Idle != nil {
+			f.Idle = *rule.Idle
+		}
+		f.Forwarded, f.KeepHost = s.forwarded, s.preserveHost
+		if rule.Forwarded != "" {
+			f.Forwarded = rule.Forwarded
+		}
+		if rule.Host != "" {
+			f.KeepHost = rule.Host == "preserve"
+		}
+	}
+	rawurl, rest := rule.target(r.URL.EscapedPath())
+	if alt, err := rule.window(now); err != nil {
+		if err == errExpired {
+			return nil, &failure{410, err.Error()}
+		}
+		return nil, &failure{404, err.Error()}
+	} else if alt != "" {
+		rawurl = alt
+	} else if c := rule.condition(r, s.geo); c != nil {
+		rawurl, rest = c.URL, r.URL.EscapedPath()
+		f.Condition = c.Expr
+	} else if len(rule.Variants) > 0 {
+		f.Variant = pickVariant(rule.Variants, s.variantID(r))
+		rawurl = f.Variant.URL
+	}
+	expanded, err := expandTemplate(rawurl, &templateEnv{r: r, res: res, geo: s.geo})
+	if err != nil {
+		return nil, &failure{500, "Invalid URL"}
+	}
+	target, err := url.Parse(expanded)
+	if err != nil {
+		return nil, &failure{500, "Invalid URL"}
+	}
+	rule.apply(target, rest, r.URL.RawQuery, f.Proxy)
+	f.target = target
+	f.Target = target.String()
+	return f, nil
+}
+
+//test evaluates a rule against a synthetic request. The rule
+//is either the "rule" TXT value or the records of "host", and
+//the request is built from the "url", "ip", "ua", "lang" and
+//"header" ("Name: value") parameters, at the given "time".
+func (s *Subfwd) test(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	host := q.Get("host")
+	rawurl := q.Get("url")
+	if rawurl == "" && host != "" {
+		rawurl = "http://" + host + "/"
+	}
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil || req.Host == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("Missing host or url"))
+		return
+	}
+	if host == "" {
+		host = req.Host
+	}
+	ip := q.Get("ip")
+	if ip == "" {
+		ip = "127.0.0.1"
+	}
+	req.RemoteAddr = net.JoinHostPort(ip, "0")
+	if ua := q.Get("ua"); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	if lang := q.Get("lang"); lang != "" {
+		req.Header.Set("Accept-Language", lang)
 	}
+	for _, h := range q["header"] {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			w.WriteHeader(400)
+			w.Write([]byte("Invalid header '" + h + "'"))
+			return
+		}
+		req.Header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	req = s.trust(req)
+	now := time.Now()
+	if t := q.Get("time"); t != "" {
+		if now, err = parseTime(t); err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Invalid time"))
+			return
+		}
+	}
+	out := struct {
+		Resolution *resolution
+		Forward    *forward `json:",omitempty"`
+		Error      string   `json:",omitempty"`
+	}{}
+	if txt := q.Get("rule"); txt != "" {
+		rule, err := ParseRule(txt)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		out.Resolution = &resolution{Host: host, Subdomain: host, Rule: rule}
+		s.settle(out.Resolution)
+	} else if out.Resolution, err = s.resolve(host); err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if verr := out.Resolution.bogus(); verr != nil {
+		out.Error = verr.Error()
+	} else if out.Resolution.Rule == nil {
+		out.Error = "No TXT"
+	} else if out.Forward, err = s.forward(req, out.Resolution, now); err != nil {
+		out.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	b, _ := json.MarshalIndent(out, "", "  ")
+	w.Write(b)
+}
+
+//records manages the TXT records of the name server: GET lists
+//them (or those of "name"), PUT replaces those of "name" with
+//the JSON array of values in the body and DELETE removes them.
+//Requests require the admin token as a bearer token.
+func (s *Subfwd) records(w http.ResponseWriter, r *http.Request) {
+	if s.ns == nil {
+		w.WriteHeader(404)
+		w.Write([]byte("Name server disabled"))
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		w.WriteHeader(401)
+		w.Write([]byte("Unauthorized"))
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" && r.Method != "GET" {
+		w.WriteHeader(400)
+		w.Write([]byte("Missing name"))
+		return
+	}
+	switch r.Method {
+	case "GET":
+	case "PUT":
+		txts := []string{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&txts); err != nil || len(txts) == 0 {
+			w.WriteHeader(400)
+			w.Write([]byte("Body must be a JSON array of TXT values"))
+			return
+		}
+		for _, txt := range txts {
+			if _, err := ParseRule(txt); err != nil && err != errNotRule {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+		if err := s.ns.SetTXT(name, txts...); err != nil {
+			s.logf("Record update failed: %s", err)
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		s.logf("Set record %s", name)
+	case "DELETE":
+		if err := s.ns.SetTXT(name); err != nil {
+			s.logf("Record update failed: %s", err)
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		s.logf("Removed record %s", name)
+	default:
+		w.WriteHeader(405)
+		return
+	}
+	records := s.ns.Records()
+	if name != "" {
+		name = canonical(name)
+		records = map[string][]string{name: records[name]}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	b, _ := json.MarshalIndent(records, "", "  ")
+	w.Write(b)
 }
 
 //=============
 
 var trimPort = regexp.MustCompile(`\:\d+$`)
-var urlVars = regexp.MustCompile(`\$(IP|DATE|HEADER\[[\w-]+\])`)
-
-func substitiute(url string, r *http.Request) string {
-	return string(urlVars.ReplaceAllFunc([]byte(url), func(input []byte) []byte {
-		s := string(input[1:])
-		var output []byte
-		switch s {
-		case "IP":
-			output = []byte(realip.RealIP(r))
-		case "DATE":
-			output = []byte(fmt.Sprintf("%d", time.Now().UnixNano()/1e6))
-		default: //"HEADER"
-			name := s[7:]
-			name = name[:len(name)-1]
-			output = []byte(r.Header.Get(name))
-		}
-		return output
-	}))
-}
 
 func randHex() string {
 	b := make([]byte, 16)
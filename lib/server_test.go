@@ -0,0 +1,175 @@
+package subfwd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//newTestServer creates a server which resolves
+//records with the returned FakeResolver
+func newTestServer(t *testing.T, c Config) (*Subfwd, *FakeResolver) {
+	f := NewFakeResolver()
+	if c.Resolver == nil {
+		c.Resolver = f
+	}
+	s, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, f
+}
+
+//do routes a request to the server
+func do(s *Subfwd, method, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.route(w, httptest.NewRequest(method, url, nil))
+	return w
+}
+
+func TestExecute(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend " + r.Host + r.URL.Path))
+	}))
+	defer backend.Close()
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-a.example.com", "v=spf1 -all", "http://a.com/")
+	f.SetTXT("subproxy-p.example.com", backend.URL+"/root")
+	f.SetTXT("subfwd-default.example.com", "http://default.com/")
+	w := do(s, "GET", "http://a.example.com/")
+	if w.Code != 302 || w.Header().Get("Location") != "http://a.com/" {
+		t.Errorf("redirect: got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	w = do(s, "GET", "http://p.example.com/")
+	if w.Code != 200 || w.Body.String() != "backend "+strings.TrimPrefix(backend.URL, "http://")+"/root" {
+		t.Errorf("proxy: got %d %q", w.Code, w.Body.String())
+	}
+	w = do(s, "GET", "http://other.example.com/")
+	if w.Code != 302 || w.Header().Get("Location") != "http://default.com/" {
+		t.Errorf("default: got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	w = do(s, "GET", "http://a.example.net/")
+	if w.Code != 404 || w.Body.String() != "Redirect failed [No TXT]" {
+		t.Errorf("missing: got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestValidCNAME(t *testing.T) {
+	s, _ := newTestServer(t, Config{CNAMEs: []string{"subfwd.herokuapp.com", "links.example.com."}})
+	for cname, want := range map[string]bool{
+		"subfwd.herokuapp.com": true,
+		"SUBFWD.herokuapp.com": true,
+		"links.example.com":    true,
+		"evil.herokuapp.com":   false,
+	} {
+		if got := s.validCNAME(cname); got != want {
+			t.Errorf("%s: got %v, want %v", cname, got, want)
+		}
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.String()))
+	}))
+	defer backend.Close()
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-a.example.com", "v=subfwd1; url=http://t.com/base?x=1&y=2; path=pass; query=pass")
+	f.SetTXT("subfwd-b.example.com", "v=subfwd1; url=http://t.com/base/?x=1; path=pass; query=override")
+	f.SetTXT("subfwd-c.example.com", "http://t.com/base?x=1")
+	f.SetTXT("subproxy-p.example.com", backend.URL+"/root?k=v")
+	f.SetTXT("subproxy-q.example.com", "v=subfwd1; url="+backend.URL+"/root?k=v; path=drop; query=drop")
+	for _, c := range []struct {
+		url, want string
+	}{
+		//escaped paths are preserved, the record's query takes precedence
+		{"http://a.example.com/api/v2%2Fx/h%20i?x=9&z=a%26b", "http://t.com/base/api/v2%2Fx/h%20i?x=1&y=2&z=a%26b"},
+		//or is overridden by the request's query
+		{"http://b.example.com/api?x=9", "http://t.com/base/api?x=9"},
+		//plain records drop both
+		{"http://c.example.com/api?x=9", "http://t.com/base?x=1"},
+	} {
+		w := do(s, "GET", c.url)
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != c.want {
+			t.Errorf("%s: got %d %q, want %q", c.url, w.Code, loc, c.want)
+		}
+	}
+	//proxies pass both through by default
+	if w := do(s, "GET", "http://p.example.com/sub/path?k=w&m=1"); w.Body.String() != "/root/sub/path?k=v&m=1" {
+		t.Errorf("proxy: got %d %q", w.Code, w.Body.String())
+	}
+	if w := do(s, "GET", "http://q.example.com/sub/path?k=w&m=1"); w.Body.String() != "/root?k=v" {
+		t.Errorf("proxy drop: got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestWhiteLabel(t *testing.T) {
+	s, f := newTestServer(t, Config{
+		AppDomain:   "fwd.acme.io",
+		AdminHosts:  []string{"admin.acme.io", "localhost:3000"},
+		Prefix:      "go",
+		ProxyPrefix: "px",
+		Naming:      "both",
+	})
+	f.SetTXT("go-a.example.com", "http://a.com")
+	f.SetTXT("px-b.example.com", "http://b.com")
+	f.SetTXT("_go.c.example.com", "http://c.com")
+	for u, want := range map[string]string{
+		"http://a.example.com/": "http://a.com",
+		"http://c.example.com/": "http://c.com",
+	} {
+		w := do(s, "GET", u)
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != want {
+			t.Errorf("%s: got %d %q, want %q", u, w.Code, loc, want)
+		}
+	}
+	if res, err := s.resolve("b.example.com"); err != nil || res.Rule == nil || !res.Proxy || res.Rule.URL != "http://b.com" {
+		t.Errorf("proxy: got %+v %v", res, err)
+	}
+	//the admin UI is served on the app domain and admin hosts
+	for u, want := range map[string]int{
+		"http://fwd.acme.io/stats":         200,
+		"http://ADMIN.acme.io/stats":       200,
+		"http://localhost:3000/stats":      200,
+		"http://subfwd.jpillora.com/stats": 404,
+	} {
+		if w := do(s, "GET", u); w.Code != want {
+			t.Errorf("%s: got %d, want %d", u, w.Code, want)
+		}
+	}
+	w := do(s, "GET", "http://fwd.acme.io/stats")
+	if !strings.Contains(w.Body.String(), `"AppDomain":"fwd.acme.io","Prefix":"go","ProxyPrefix":"px"`) {
+		t.Errorf("got %s", w.Body.String())
+	}
+	if !s.validCNAME("FWD.acme.io") || s.validCNAME("subfwd.herokuapp.com") {
+		t.Error("expected the app domain to be the only accepted CNAME")
+	}
+	if _, err := New(Config{Prefix: "x", ProxyPrefix: "x", Resolver: f}); err == nil {
+		t.Error("expected a prefix conflict error")
+	}
+}
+
+//TestConcurrentStats is run with -race to check the
+//stats are only accessed under the stats lock
+func TestConcurrentStats(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-a.example.com", "http://a.com")
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for j := 0; j < 50; j++ {
+				do(s, "GET", "http://a.example.com/")
+				do(s, "GET", "http://subfwd.jpillora.com/stats")
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	w := do(s, "GET", "http://subfwd.jpillora.com/stats")
+	if !strings.Contains(w.Body.String(), `"Success":200`) {
+		t.Errorf("got %s", w.Body.String())
+	}
+}
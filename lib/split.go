@@ -0,0 +1,138 @@
+package subfwd
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//variantCookie holds the id of the variant
+//of a visitor when sticky variants are enabled
+const variantCookie = appName + "-variant"
+
+//maxWeight bounds the weight of a variant
+const maxWeight = 1000000
+
+//Variant is one of the weighted targets of a split Rule.
+//Each variant is a TXT value of the form:
+//
+//	w=80 url=https://example.com/a
+//
+//and all of the variants of a record form a single
+//rule, splitting traffic in proportion to their weights.
+type Variant struct {
+	URL    string
+	Weight int
+}
+
+//parseVariant parses a single "w=<weight> url=<url>" value
+func parseVariant(txt string) (*Variant, error) {
+	v := &Variant{}
+	for _, field := range strings.Fields(txt) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("missing '=' in '%s'", field)
+		}
+		switch kv[0] {
+		case "w":
+			w, err := strconv.Atoi(kv[1])
+			if err != nil || w <= 0 || w > maxWeight {
+				return nil, fmt.Errorf("invalid weight '%s'", kv[1])
+			}
+			v.Weight = w
+		case "url":
+			if err := checkURL(kv[1]); err != nil {
+				return nil, err
+			}
+			v.URL = kv[1]
+		default:
+			return nil, fmt.Errorf("unknown key '%s'", kv[0])
+		}
+	}
+	if v.URL == "" {
+		return nil, errors.New("missing url")
+	} else if v.Weight == 0 {
+		return nil, errors.New("missing weight")
+	}
+	return v, nil
+}
+
+//checkWeights returns an error when the total weight of the
+//variants is zero, or overflows (an int32, on any platform)
+func checkWeights(variants []*Variant) error {
+	total := 0
+	for _, v := range variants {
+		if v.Weight <= 0 || v.Weight > math.MaxInt32-total {
+			return errors.New("invalid total weight")
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return errors.New("invalid total weight")
+	}
+	return nil
+}
+
+//id identifies the variant in the sticky cookie
+func (v *Variant) id() string {
+	h := fnv.New32a()
+	h.Write([]byte(v.URL))
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
+
+//pickVariant returns the variant with the given id, or
+//otherwise picks one randomly, in proportion to their weights
+func pickVariant(variants []*Variant, id string) *Variant {
+	total := 0
+	for _, v := range variants {
+		if id != "" && v.id() == id {
+			return v
+		}
+		total += v.Weight
+	}
+	n := rand.Intn(total)
+	for _, v := range variants {
+		n -= v.Weight
+		if n < 0 {
+			return v
+		}
+	}
+	return variants[len(variants)-1]
+}
+
+//variantID returns the sticky variant id of the request, if any
+func (s *Subfwd) variantID(r *http.Request) string {
+	if s.sticky > 0 {
+		if c, err := r.Cookie(variantCookie); err == nil {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+//useVariant counts a hit of the selected variant and, when
+//sticky, keeps the visitor on it for the sticky period
+func (s *Subfwd) useVariant(w http.ResponseWriter, r *http.Request, res *resolution, v *Variant) {
+	if s.sticky > 0 && v.id() != s.variantID(r) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     variantCookie,
+			Value:    v.id(),
+			Path:     "/",
+			MaxAge:   int(s.sticky.Seconds()),
+			HttpOnly: true,
+		})
+	}
+	s.mut.Lock()
+	hits, ok := s.stats.Variants[res.Subdomain]
+	if !ok {
+		hits = map[string]uint{}
+		s.stats.Variants[res.Subdomain] = hits
+	}
+	hits[v.URL]++
+	s.mut.Unlock()
+}
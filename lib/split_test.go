@@ -0,0 +1,108 @@
+package subfwd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSplit(t *testing.T) {
+	s, f := newTestServer(t, Config{Sticky: time.Hour})
+	//variants take precedence over plain values
+	f.SetTXT("subfwd-ab.example.com", "http://first.com", "w=80 url=http://a.com", "w=20 url=http://b.com")
+	f.SetTXT("subfwd-bad.example.com", "w=0 url=http://a.com", "http://ok.com")
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		w := do(s, "GET", "http://ab.example.com/")
+		counts[w.Header().Get("Location")]++
+	}
+	if len(counts) != 2 || counts["http://a.com"] < 700 || counts["http://b.com"] < 100 {
+		t.Errorf("got %v", counts)
+	}
+	//hits are counted per variant
+	w := do(s, "GET", "http://subfwd.jpillora.com/stats")
+	stats := struct {
+		Variants map[string]map[string]int
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if hits := stats.Variants["ab.example.com"]; hits["http://a.com"] != counts["http://a.com"] || hits["http://b.com"] != counts["http://b.com"] {
+		t.Errorf("got %v, want %v", hits, counts)
+	}
+	//invalid variants are skipped
+	if w := do(s, "GET", "http://bad.example.com/"); w.Header().Get("Location") != "http://ok.com" {
+		t.Errorf("got %q", w.Header().Get("Location"))
+	}
+}
+
+func TestSticky(t *testing.T) {
+	s, f := newTestServer(t, Config{Sticky: time.Hour})
+	f.SetTXT("subfwd-ab.example.com", "w=50 url=http://a.com", "w=50 url=http://b.com")
+	w := do(s, "GET", "http://ab.example.com/")
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != variantCookie || cookies[0].MaxAge != 3600 {
+		t.Fatalf("got %v", cookies)
+	}
+	want := w.Header().Get("Location")
+	for i := 0; i < 20; i++ {
+		r := httptest.NewRequest("GET", "http://ab.example.com/", nil)
+		r.AddCookie(&http.Cookie{Name: variantCookie, Value: cookies[0].Value})
+		rw := httptest.NewRecorder()
+		s.route(rw, r)
+		if loc := rw.Header().Get("Location"); loc != want || rw.Header().Get("Set-Cookie") != "" {
+			t.Fatalf("got %q %q, want %q", loc, rw.Header().Get("Set-Cookie"), want)
+		}
+	}
+}
+
+func TestParseVariant(t *testing.T) {
+	if v, err := parseVariant("w=5 url=http://a.com"); err != nil || *v != (Variant{URL: "http://a.com", Weight: 5}) {
+		t.Errorf("got %v %v", v, err)
+	}
+	for txt, want := range map[string]string{
+		"w=0 url=http://a.com":                   "invalid weight '0'",
+		"w=x url=http://a.com":                   "invalid weight 'x'",
+		"w=1000001 url=http://a.com":             "invalid weight '1000001'",
+		"w=9223372036854775807 url=http://a.com": "invalid weight '9223372036854775807'",
+		"w=1":                                    "missing url",
+		"url=http://a.com":                       "missing weight",
+		"w=1 url=ftp://a.com":                    "invalid url 'ftp://a.com'",
+		"w=1 url=http://a.com x":                 "missing '=' in 'x'",
+		"w=1 url=http://a.com foo=1":             "unknown key 'foo'",
+	} {
+		if _, err := parseVariant(txt); err == nil || err.Error() != want {
+			t.Errorf("%q: got %v, want %q", txt, err, want)
+		}
+	}
+}
+
+//TestWeightOverflow checks the total weight of a split
+//cannot overflow, which would panic on each request
+func TestWeightOverflow(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	max := "w=9223372036854775807 url=http://a.com"
+	f.SetTXT("subfwd-a.example.com", max, max)
+	f.SetTXT("subfwd-b.example.com", "v=subfwd1; variant=\""+max+"\"; variant=\""+max+"\"")
+	f.SetTXT("subfwd-c.example.com", "w=1000000 url=http://c.com", "w=1000000 url=http://d.com")
+	for host, want := range map[string]int{"a": 404, "b": 404, "c": 302} {
+		if w := do(s, "GET", "http://"+host+".example.com/"); w.Code != want {
+			t.Errorf("%s: got %d, want %d", host, w.Code, want)
+		}
+	}
+	variants := []*Variant{}
+	for i := 0; i < 2148; i++ {
+		variants = append(variants, &Variant{URL: "http://a.com", Weight: maxWeight})
+	}
+	if err := checkWeights(variants); err == nil {
+		t.Error("expected an overflowing total to be rejected")
+	}
+	if err := checkWeights(variants[:2147]); err != nil {
+		t.Error(err)
+	}
+	if err := checkWeights([]*Variant{{URL: "http://a.com"}}); err == nil {
+		t.Error("expected a zero total to be rejected")
+	}
+}
@@ -0,0 +1,274 @@
+package subfwd
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Target URLs are templates, variables are written as $NAME,
+//$NAME[arg] or, with formatting functions, ${NAME[arg]|fn|fn:arg}.
+//
+//Variables:
+//
+//	$IP                 requester's IP address
+//	$DATE               unix epoch in milliseconds
+//	$HOST               request host (without the port)
+//	$SUBDOMAIN          request subdomain (e.g. "a" of a.example.com)
+//	$PATH               request path
+//	$UA                 request User-Agent
+//	$UUID               random (version 4) UUID
+//	$COUNTRY            requester's country code (requires a GeoDB)
+//	$CONTINENT          requester's continent code (requires a GeoDB)
+//	$HEADER[Name]       request header
+//	$QUERY[name]        request query parameter
+//	$COOKIE[name]       request cookie
+//
+//Functions:
+//
+//	lower, upper        change the case of the value
+//	default:<value>     use the given value when empty
+//	date:<layout>       format a millisecond epoch ($DATE) with a Go
+//	                    time layout, or "iso" (RFC3339) or "unix"
+//	raw                 do not escape the value
+//
+//Values are escaped for the part of the URL they are in: host
+//values may only contain letters, digits, dots and dashes, path
+//and fragment values are escaped as a path segment, and query
+//values as a query component. Unknown $NAME variables are left
+//as-is, however unknown variables and functions within ${...}
+//are rejected.
+var templateVars = regexp.MustCompile(`\$\{([^}]*)\}|\$([A-Z]+)(\[[\w-]+\])?`)
+
+//templateArgs are the variables which take an [arg]
+var templateArgs = map[string]bool{"HEADER": true, "QUERY": true, "COOKIE": true}
+
+//templateNames are the variables without an [arg]
+var templateNames = map[string]bool{
+	"IP": true, "DATE": true, "HOST": true, "SUBDOMAIN": true, "PATH": true,
+	"UA": true, "UUID": true, "COUNTRY": true, "CONTINENT": true,
+}
+
+var hostUnsafe = regexp.MustCompile(`[^A-Za-z0-9.-]`)
+
+//urlPart is the part of the URL a variable is in
+type urlPart int
+
+const (
+	partHost urlPart = iota
+	partPath
+	partQuery
+	partFragment
+)
+
+//templateVar is a variable within a target URL
+type templateVar struct {
+	name  string
+	arg   string
+	funcs [][2]string
+	part  urlPart
+}
+
+//templatePiece is either literal text or a variable
+type templatePiece struct {
+	text string
+	v    *templateVar
+}
+
+//templateEnv is the request a template is expanded for
+type templateEnv struct {
+	r   *http.Request
+	res *resolution
+	geo *GeoDB
+}
+
+//parseTemplate splits the given target URL into its
+//literal text and variables, noting the part of the
+//URL each variable is in
+func parseTemplate(s string) ([]templatePiece, error) {
+	pieces := []templatePiece{}
+	literal := ""
+	text := func(t string) {
+		literal += t
+		pieces = append(pieces, templatePiece{text: t})
+	}
+	last := 0
+	for _, m := range templateVars.FindAllStringSubmatchIndex(s, -1) {
+		v := &templateVar{}
+		if m[2] >= 0 {
+			spec := strings.Split(s[m[2]:m[3]], "|")
+			name := spec[0]
+			if i := strings.Index(name, "["); i >= 0 && strings.HasSuffix(name, "]") {
+				name, v.arg = name[:i], name[i+1:len(name)-1]
+			}
+			v.name = name
+			if !templateNames[name] && !(templateArgs[name] && v.arg != "") {
+				return nil, fmt.Errorf("unknown variable '%s'", s[m[0]:m[1]])
+			}
+			for _, fn := range spec[1:] {
+				kv := strings.SplitN(fn, ":", 2)
+				if len(kv) == 1 {
+					kv = append(kv, "")
+				}
+				switch kv[0] {
+				case "lower", "upper", "raw", "default", "date":
+				default:
+					return nil, fmt.Errorf("unknown function '%s'", kv[0])
+				}
+				v.funcs = append(v.funcs, [2]string{kv[0], kv[1]})
+			}
+		} else {
+			v.name = s[m[4]:m[5]]
+			if m[6] >= 0 {
+				v.arg = s[m[6]+1 : m[7]-1]
+			}
+			if !templateNames[v.name] && !templateArgs[v.name] || templateArgs[v.name] != (v.arg != "") {
+				continue //not a variable
+			}
+		}
+		text(s[last:m[0]])
+		v.part = urlPartOf(literal)
+		pieces = append(pieces, templatePiece{v: v})
+		last = m[1]
+	}
+	text(s[last:])
+	return pieces, nil
+}
+
+//urlPartOf returns the part of the URL following the
+//given literal text, values before the path (i.e. the
+//scheme or host) are treated as host values
+func urlPartOf(prefix string) urlPart {
+	if strings.Contains(prefix, "#") {
+		return partFragment
+	} else if strings.Contains(prefix, "?") {
+		return partQuery
+	} else if i := strings.Index(prefix, "://"); i >= 0 && strings.Contains(prefix[i+3:], "/") {
+		return partPath
+	}
+	return partHost
+}
+
+//expandTemplate returns the given target URL with
+//its variables replaced with their escaped values
+func expandTemplate(s string, env *templateEnv) (string, error) {
+	pieces, err := parseTemplate(s)
+	if err != nil {
+		return "", err
+	}
+	out := ""
+	for _, p := range pieces {
+		if p.v == nil {
+			out += p.text
+		} else {
+			out += env.expand(p.v)
+		}
+	}
+	return out, nil
+}
+
+//expand returns the formatted and escaped value of a variable
+func (env *templateEnv) expand(v *templateVar) string {
+	r := env.r
+	val := ""
+	escaped := false
+	switch v.name {
+	case "IP":
+		val = clientIP(r)
+	case "DATE":
+		val = strconv.FormatInt(time.Now().UnixNano()/1e6, 10)
+	case "HOST":
+		val = trimPort.ReplaceAllString(r.Host, "")
+	case "SUBDOMAIN":
+		if env.res != nil && env.res.Subdomain != env.res.Domain {
+			val = strings.TrimSuffix(env.res.Subdomain, "."+env.res.Domain)
+		}
+	case "PATH":
+		val = r.URL.EscapedPath()
+		escaped = true
+	case "UA":
+		val = r.UserAgent()
+	case "UUID":
+		val = newUUID()
+	case "COUNTRY", "CONTINENT":
+		country, continent := env.geo.Lookup(net.ParseIP(clientIP(r)))
+		if v.name == "COUNTRY" {
+			val = country
+		} else {
+			val = continent
+		}
+	case "HEADER":
+		val = r.Header.Get(v.arg)
+	case "QUERY":
+		val = r.URL.Query().Get(v.arg)
+	case "COOKIE":
+		if c, err := r.Cookie(v.arg); err == nil {
+			val = c.Value
+		}
+	}
+	raw := false
+	for _, fn := range v.funcs {
+		switch fn[0] {
+		case "lower":
+			val = strings.ToLower(val)
+		case "upper":
+			val = strings.ToUpper(val)
+		case "default":
+			if val == "" {
+				val = fn[1]
+			}
+		case "date":
+			val = formatDate(val, fn[1])
+		case "raw":
+			raw = true
+		}
+	}
+	if raw {
+		return val
+	}
+	if escaped {
+		//only the path is already escaped
+		if v.part == partPath || v.part == partFragment {
+			return val
+		}
+		val, _ = url.PathUnescape(val)
+	}
+	switch v.part {
+	case partHost:
+		return hostUnsafe.ReplaceAllString(val, "")
+	case partQuery:
+		return url.QueryEscape(val)
+	}
+	return url.PathEscape(val)
+}
+
+//formatDate formats the given millisecond epoch
+func formatDate(ms, layout string) string {
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return ms
+	}
+	t := time.Unix(0, n*1e6).UTC()
+	switch layout {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "iso", "":
+		layout = time.RFC3339
+	}
+	return t.Format(layout)
+}
+
+//newUUID returns a random (version 4) UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
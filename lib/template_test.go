@@ -0,0 +1,63 @@
+package subfwd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTemplate(t *testing.T) {
+	s, f := newTestServer(t, Config{})
+	f.SetTXT("subfwd-t.a.example.com", `v=subfwd1; url="https://$SUBDOMAIN.x.com/u/$HEADER[X-U]/${QUERY[q]|upper}/$PATH?h=$HEADER[X-U]&ip=$IP&d=${DATE|date:2006}&c=${COOKIE[c]|default:none}&ua=$UA&r=${HEADER[X-U]|raw}#$HOST"`)
+	//host values may not inject other characters
+	f.SetTXT("subfwd-h.example.com", `https://${HEADER[X-H]|lower}.x.com/$NOTAVAR/$HEADER`)
+	f.SetTXT("subfwd-id.example.com", `https://x.com/$UUID`)
+	//route captures are not expanded
+	f.SetTXT("subfwd-rt.example.com", `v=subfwd1; url=https://x.com; route=/gh/* https://github.com/$1`)
+	year := strconv.Itoa(time.Now().Year())
+	for u, want := range map[string]string{
+		"http://t.a.example.com/a%2Fb/c?q=hi": "https://t.a.x.com/u/a%2Fb%3Fc=d&e%23f/HI//a%2Fb/c?h=a%2Fb%3Fc%3Dd%26e%23f&ip=192.0.2.1&d=" + year + "&c=none&ua=Moz+1.0&r=a/b?c=d&e#f%23t.a.example.com",
+		"http://h.example.com/":               "https://evil.compath.x.com/$NOTAVAR/$HEADER",
+		"http://rt.example.com/gh/$IP/x":      "https://github.com/%24IP/x",
+	} {
+		r := httptest.NewRequest("GET", u, nil)
+		r.Header.Set("X-U", "a/b?c=d&e#f")
+		r.Header.Set("X-H", "Evil.com/path?")
+		r.Header.Set("User-Agent", "Moz 1.0")
+		w := httptest.NewRecorder()
+		s.route(w, r)
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != want {
+			t.Errorf("%s: got %d %q, want %q", u, w.Code, loc, want)
+		}
+	}
+	uuid := regexp.MustCompile(`^https://x\.com/[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := do(s, "GET", "http://id.example.com/"), do(s, "GET", "http://id.example.com/")
+	if loc := a.Header().Get("Location"); !uuid.MatchString(loc) || loc == b.Header().Get("Location") {
+		t.Errorf("got %q %q", loc, b.Header().Get("Location"))
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	for raw, want := range map[string]string{
+		`https://x.com/${NOPE}`:    "invalid url 'https://x.com/${NOPE}': unknown variable '${NOPE}'",
+		`https://x.com/${IP|nope}`: "invalid url 'https://x.com/${IP|nope}': unknown function 'nope'",
+	} {
+		if err := checkURL(raw); err == nil || err.Error() != want {
+			t.Errorf("%s: got %v, want %q", raw, err, want)
+		}
+	}
+	s, f := newTestServer(t, Config{AdminHosts: []string{"admin.test"}})
+	f.SetTXT("subfwd-bad.example.com", `https://x.com/${NOPE}`)
+	if w := do(s, "GET", "http://bad.example.com/"); w.Code != 404 {
+		t.Errorf("got %d", w.Code)
+	}
+	r := httptest.NewRequest("GET", "http://admin.test/diagnose?host=bad.example.com", nil)
+	w := httptest.NewRecorder()
+	s.route(w, r)
+	if w.Code != http.StatusOK || !regexp.MustCompile(`unknown variable '\$\{NOPE\}'`).MatchString(w.Body.String()) {
+		t.Errorf("got %d %s", w.Code, w.Body.String())
+	}
+}
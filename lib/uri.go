@@ -0,0 +1,84 @@
+package subfwd
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const typeURI = dnsmessage.Type(256)
+
+var errNoURI = errors.New("URI records are not supported by this resolver")
+
+//URI is an RFC 7553 URI resource record
+type URI struct {
+	Priority uint16
+	Weight   uint16
+	Target   string
+}
+
+//URIResolver is implemented by resolvers which can look
+//up URI records, along with their smallest time-to-live
+type URIResolver interface {
+	LookupURI(name string) ([]*URI, time.Duration, error)
+}
+
+//selectURI picks a URI from those with the lowest
+//priority, randomly, in proportion to their weights
+func selectURI(uris []*URI) *URI {
+	best := []*URI{}
+	for _, u := range uris {
+		if len(best) == 0 || u.Priority < best[0].Priority {
+			best = []*URI{u}
+		} else if u.Priority == best[0].Priority {
+			best = append(best, u)
+		}
+	}
+	if len(best) == 0 {
+		return nil
+	}
+	total := 0
+	for _, u := range best {
+		total += int(u.Weight)
+	}
+	if total == 0 {
+		return best[rand.Intn(len(best))]
+	}
+	n := rand.Intn(total)
+	for _, u := range best {
+		n -= int(u.Weight)
+		if n < 0 {
+			return u
+		}
+	}
+	return best[len(best)-1]
+}
+
+func uriAnswers(msg *dnsmessage.Message, name, server string) ([]*URI, time.Duration, error) {
+	owner := answerOwner(msg, name, typeURI)
+	uris := []*URI{}
+	ttl := uint32(0)
+	for _, a := range msg.Answers {
+		body, ok := a.Body.(*dnsmessage.UnknownResource)
+		if !ok || body.Type != typeURI || len(body.Data) < 5 ||
+			!strings.EqualFold(a.Header.Name.String(), owner) {
+			continue
+		}
+		uris = append(uris, &URI{
+			Priority: binary.BigEndian.Uint16(body.Data),
+			Weight:   binary.BigEndian.Uint16(body.Data[2:]),
+			Target:   string(body.Data[4:]),
+		})
+		if len(uris) == 1 || a.Header.TTL < ttl {
+			ttl = a.Header.TTL
+		}
+	}
+	if len(uris) == 0 {
+		return nil, 0, notFound(name, server)
+	}
+	return uris, time.Duration(ttl) * time.Second, nil
+}
@@ -0,0 +1,107 @@
+package subfwd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func uriRR(name string, priority, weight uint16, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: typeURI, Class: dnsmessage.ClassINET, TTL: 60},
+		Body: &dnsmessage.UnknownResource{Type: typeURI, Data: append([]byte{
+			byte(priority >> 8), byte(priority), byte(weight >> 8), byte(weight),
+		}, target...)},
+	}
+}
+
+func TestURI(t *testing.T) {
+	s, f := newTestServer(t, Config{CacheSize: 100})
+	f.SetURI("_subfwd.a.example.com",
+		&URI{Priority: 10, Weight: 1, Target: "http://low.com"},
+		&URI{Priority: 1, Weight: 80, Target: "http://a80.com"},
+		&URI{Priority: 1, Weight: 20, Target: "http://a20.com"})
+	f.SetTXT("subfwd-a.example.com", "http://txt.com")
+	f.SetURI("_subproxy.b.example.com", &URI{Priority: 1, Weight: 1, Target: "ftp://bad"})
+	f.SetTXT("subfwd-b.example.com", "http://btxt.com")
+	//the lowest priority is selected by weight
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		w := do(s, "GET", "http://a.example.com/")
+		counts[w.Header().Get("Location")]++
+	}
+	if len(counts) != 2 || counts["http://a80.com"] < 700 || counts["http://a20.com"] < 100 {
+		t.Errorf("got %v", counts)
+	}
+	//invalid URIs fall back to the TXT records
+	w := do(s, "GET", "http://b.example.com/")
+	if w.Code != 302 || w.Header().Get("Location") != "http://btxt.com" {
+		t.Errorf("got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestUpstreamURI(t *testing.T) {
+	addr := startDNS(t, func(q *dnsmessage.Message, network string) []*dnsmessage.Message {
+		name := q.Questions[0].Name.String()
+		if name != "_subfwd.a.com." {
+			m := reply(q)
+			m.RCode = dnsmessage.RCodeNameError
+			return []*dnsmessage.Message{m}
+		}
+		return []*dnsmessage.Message{reply(q,
+			uriRR(name, 1, 5, "https://wire.com"),
+			//records of other owners are ignored
+			uriRR("_subfwd.evil.com.", 1, 5, "https://evil.com"))}
+	})
+	r := NewUpstreamResolver(addr, "udp")
+	uris, ttl, err := r.LookupURI("_subfwd.a.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uris) != 1 || *uris[0] != (URI{Priority: 1, Weight: 5, Target: "https://wire.com"}) || ttl != time.Minute {
+		t.Errorf("got %v %s", uris, ttl)
+	}
+	if _, _, err := r.LookupURI("_subfwd.b.com"); !isNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestSystemURI(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "resolv.conf")
+	defer func(path string) { resolvConf = path }(resolvConf)
+	resolvConf = conf
+	for contents, want := range map[string]string{
+		"# comment\nsearch example.com\nnameserver 10.0.0.1\nnameserver 10.0.0.2\n": "10.0.0.1:53",
+		"nameserver ::1":     "[::1]:53",
+		"search example.com": "",
+	} {
+		ioutil.WriteFile(conf, []byte(contents), 0600)
+		got := ""
+		if r := NewSystemResolver(); r.Upstream != nil {
+			got = r.Upstream.Addr
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", contents, got, want)
+		}
+	}
+	//without a nameserver, URI records are not looked up
+	ioutil.WriteFile(conf, []byte("search example.com"), 0600)
+	s, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.resolver.(*SystemResolver); !ok || s.lookupURIs {
+		t.Errorf("got %T %v", s.resolver, s.lookupURIs)
+	}
+	//otherwise they are looked up from the nameserver
+	addr := startDNS(t, func(q *dnsmessage.Message, network string) []*dnsmessage.Message {
+		return []*dnsmessage.Message{reply(q, uriRR(q.Questions[0].Name.String(), 1, 1, "https://sys.com"))}
+	})
+	r := &SystemResolver{Upstream: NewUpstreamResolver(addr, "udp")}
+	if uris, _, err := r.LookupURI("_subfwd.a.com"); err != nil || len(uris) != 1 || uris[0].Target != "https://sys.com" {
+		t.Errorf("got %v %v", uris, err)
+	}
+}
@@ -0,0 +1,60 @@
+package subfwd
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+//fileWatcher reloads a file when its modification
+//time changes, which is checked every interval
+type fileWatcher struct {
+	path     string
+	interval time.Duration
+	//reload applies the contents of the file,
+	//on error the previous contents are kept
+	reload  func(b []byte) error
+	mut     sync.Mutex
+	modTime time.Time
+}
+
+//load reloads the file when it has changed, or when forced
+func (w *fileWatcher) load(force bool) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(w.modTime) && !force {
+		return nil
+	}
+	b, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if err := w.reload(b); err != nil {
+		return err
+	}
+	w.modTime = info.ModTime()
+	return nil
+}
+
+//watch loads the file every interval, and forcibly on
+//each of the given signals (which may be nil), passing
+//failures to fail
+func (w *fileWatcher) watch(signals <-chan os.Signal, fail func(error)) {
+	tick := time.NewTicker(w.interval)
+	for {
+		force := false
+		select {
+		case <-signals:
+			force = true
+		case <-tick.C:
+		}
+		if err := w.load(force); err != nil {
+			fail(err)
+		}
+	}
+}
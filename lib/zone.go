@@ -0,0 +1,501 @@
+package subfwd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//defaultZoneTTL is the TTL of records without one
+//when the zone file has no $TTL directive
+const defaultZoneTTL = 3600
+
+//maxZoneIncludes bounds the nesting of $INCLUDE directives
+const maxZoneIncludes = 8
+
+//maxCNAMEChain bounds the CNAMEs followed by a lookup
+const maxCNAMEChain = 8
+
+//ZoneResolver is an authoritative resolver, serving the
+//records of RFC 1035 (BIND-style) zone files, for local
+//development, testing and air-gapped use. TXT, CNAME and
+//URI records are served, other types are ignored. The
+//$ORIGIN, $TTL and $INCLUDE directives are supported and
+//a file named <origin>.zone has that initial origin.
+//Wildcards (*.example.com) match names which do not exist,
+//and CNAMEs are followed. A wildcard TXT record matches every
+//prefix, so with the legacy naming scheme *.links would also
+//answer subproxy-<sub>.links, proxying every forward. Instead,
+//use explicit records or the underscore naming scheme, where
+//the mode is set within the record. For example, with
+//--naming underscore, example.com.zone:
+//
+//	$TTL 60
+//	_subfwd.go    TXT  "https://golang.org"
+//	_subfwd.docs  URI  10 1 "https://example.com/docs"
+//	*.links       TXT  "v=subfwd1; url=https://example.com/$SUBDOMAIN"
+type ZoneResolver struct {
+	Paths   []string
+	records map[string][]*zoneRecord
+	nodes   map[string]bool
+}
+
+//zoneRecord is a single record of a zone file
+type zoneRecord struct {
+	Type  string
+	TTL   uint32
+	TXT   string
+	CNAME string
+	URI   *URI
+}
+
+//NewZoneResolver loads the given zone files
+func NewZoneResolver(paths ...string) (*ZoneResolver, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no zone files")
+	}
+	z := &ZoneResolver{
+		Paths:   paths,
+		records: map[string][]*zoneRecord{},
+		nodes:   map[string]bool{},
+	}
+	for _, path := range paths {
+		origin := ""
+		if base := filepath.Base(path); strings.HasSuffix(base, ".zone") {
+			origin = canonical(strings.TrimSuffix(base, ".zone"))
+		}
+		p := &zoneParser{z: z, origin: origin, ttl: defaultZoneTTL}
+		if err := p.parseFile(path, 0); err != nil {
+			return nil, err
+		}
+	}
+	return z, nil
+}
+
+//LookupTXT returns the TXT records of the given name
+func (z *ZoneResolver) LookupTXT(name string) ([]string, error) {
+	txts, _, err := z.LookupTXTTTL(name)
+	return txts, err
+}
+
+//LookupTXTTTL returns the TXT records of the given
+//name along with their smallest time-to-live
+func (z *ZoneResolver) LookupTXTTTL(name string) ([]string, time.Duration, error) {
+	recs, err := z.lookup(name, "TXT")
+	if err != nil {
+		return nil, 0, err
+	}
+	txts := []string{}
+	for _, rec := range recs {
+		txts = append(txts, rec.TXT)
+	}
+	return txts, minTTL(recs), nil
+}
+
+//LookupURI returns the URI records of the given
+//name along with their smallest time-to-live
+func (z *ZoneResolver) LookupURI(name string) ([]*URI, time.Duration, error) {
+	recs, err := z.lookup(name, "URI")
+	if err != nil {
+		return nil, 0, err
+	}
+	uris := []*URI{}
+	for _, rec := range recs {
+		u := *rec.URI
+		uris = append(uris, &u)
+	}
+	return uris, minTTL(recs), nil
+}
+
+//LookupCNAME returns the canonical name of the
+//given name, following the chain to its end
+func (z *ZoneResolver) LookupCNAME(name string) (string, error) {
+	cname := ""
+	for i := 0; i < maxCNAMEChain; i++ {
+		recs := z.find(name)
+		if len(recs) == 0 || recs[0].Type != "CNAME" {
+			if cname == "" {
+				return "", notFound(name, "zone")
+			}
+			return cname + ".", nil
+		}
+		cname = recs[0].CNAME
+		name = cname
+	}
+	return "", &net.DNSError{Err: "CNAME chain too long", Name: name, Server: "zone"}
+}
+
+//lookup returns the records of the given type,
+//following CNAMEs from the given name
+func (z *ZoneResolver) lookup(name, rtype string) ([]*zoneRecord, error) {
+	for i := 0; i < maxCNAMEChain; i++ {
+		matches := []*zoneRecord{}
+		cname := ""
+		for _, rec := range z.find(name) {
+			if rec.Type == rtype {
+				matches = append(matches, rec)
+			} else if rec.Type == "CNAME" {
+				cname = rec.CNAME
+			}
+		}
+		if len(matches) > 0 {
+			return matches, nil
+		} else if cname == "" {
+			return nil, notFound(name, "zone")
+		}
+		name = cname
+	}
+	return nil, &net.DNSError{Err: "CNAME chain too long", Name: name, Server: "zone"}
+}
+
+//find returns the records of the given name, or when the name
+//does not exist, those of the wildcard of its closest encloser
+func (z *ZoneResolver) find(name string) []*zoneRecord {
+	name = canonical(name)
+	if z.nodes[name] {
+		return z.records[name]
+	}
+	labels := strings.Split(name, ".")
+	for i := 1; i < len(labels); i++ {
+		encloser := strings.Join(labels[i:], ".")
+		if z.nodes[encloser] {
+			return z.records["*."+encloser]
+		}
+	}
+	return nil
+}
+
+//add adds a record, along with the (empty) nodes above it
+func (z *ZoneResolver) add(name string, rec *zoneRecord) {
+	if rec != nil {
+		z.records[name] = append(z.records[name], rec)
+	}
+	for n := name; n != ""; {
+		z.nodes[n] = true
+		i := strings.Index(n, ".")
+		if i == -1 {
+			break
+		}
+		n = n[i+1:]
+	}
+}
+
+func minTTL(recs []*zoneRecord) time.Duration {
+	ttl := recs[0].TTL
+	for _, rec := range recs {
+		if rec.TTL < ttl {
+			ttl = rec.TTL
+		}
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+//zoneToken is a word or quoted string of a zone file
+type zoneToken struct {
+	text   string
+	quoted bool
+}
+
+//zoneLine is a logical line of a zone file (parentheses
+//join physical lines), blank lines omit their owner
+type zoneLine struct {
+	num    int
+	blank  bool
+	tokens []zoneToken
+}
+
+//zoneParser parses a zone file into its resolver
+type zoneParser struct {
+	z      *ZoneResolver
+	origin string
+	ttl    uint32
+	owner  string
+}
+
+func (p *zoneParser) parseFile(path string, depth int) error {
+	if depth > maxZoneIncludes {
+		return fmt.Errorf("%s: too many nested includes", path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines, err := lexZone(string(b))
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	for _, l := range lines {
+		if err := p.parseLine(path, l, depth); err != nil {
+			return fmt.Errorf("%s:%d: %s", path, l.num, err)
+		}
+	}
+	return nil
+}
+
+func (p *zoneParser) parseLine(path string, l zoneLine, depth int) error {
+	toks := l.tokens
+	switch first := toks[0]; {
+	case !first.quoted && first.text == "$ORIGIN":
+		if len(toks) != 2 {
+			return errors.New("expected $ORIGIN <name>")
+		}
+		origin, err := p.name(toks[1].text)
+		if err != nil {
+			return err
+		}
+		p.origin = origin
+		return nil
+	case !first.quoted && first.text == "$TTL":
+		if len(toks) != 2 {
+			return errors.New("expected $TTL <ttl>")
+		}
+		ttl, err := parseZoneTTL(toks[1].text)
+		if err != nil {
+			return err
+		}
+		p.ttl = ttl
+		return nil
+	case !first.quoted && first.text == "$INCLUDE":
+		if len(toks) != 2 && len(toks) != 3 {
+			return errors.New("expected $INCLUDE <file> [origin]")
+		}
+		file := toks[1].text
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		//the included file has its own origin and
+		//owner, which are restored once it is parsed
+		sub := *p
+		if len(toks) == 3 {
+			origin, err := p.name(toks[2].text)
+			if err != nil {
+				return err
+			}
+			sub.origin = origin
+		}
+		return sub.parseFile(file, depth+1)
+	case !first.quoted && strings.HasPrefix(first.text, "$"):
+		return fmt.Errorf("unknown directive '%s'", first.text)
+	}
+	if !l.blank {
+		owner, err := p.name(toks[0].text)
+		if err != nil {
+			return err
+		}
+		p.owner = owner
+		toks = toks[1:]
+	} else if p.owner == "" {
+		return errors.New("missing owner name")
+	}
+	//an optional TTL and class, in either order
+	ttl := p.ttl
+	for i := 0; i < 2 && len(toks) > 0; i++ {
+		t := toks[0].text
+		if t != "" && t[0] >= '0' && t[0] <= '9' {
+			n, err := parseZoneTTL(t)
+			if err != nil {
+				return err
+			}
+			ttl = n
+		} else if u := strings.ToUpper(t); u == "IN" || u == "CH" || u == "HS" || u == "CS" {
+			if u != "IN" {
+				return fmt.Errorf("unsupported class '%s'", t)
+			}
+		} else {
+			break
+		}
+		toks = toks[1:]
+	}
+	if len(toks) == 0 {
+		return errors.New("missing record type")
+	}
+	rtype := strings.ToUpper(toks[0].text)
+	rdata := toks[1:]
+	rec := &zoneRecord{Type: rtype, TTL: ttl}
+	switch rtype {
+	case "TXT":
+		if len(rdata) == 0 {
+			return errors.New("missing TXT data")
+		}
+		//join multiple strings, as does net.LookupTXT
+		for _, t := range rdata {
+			if len(t.text) > 255 {
+				return errors.New("TXT string longer than 255 bytes")
+			}
+			rec.TXT += t.text
+		}
+	case "CNAME":
+		if len(rdata) != 1 {
+			return errors.New("expected CNAME <name>")
+		}
+		cname, err := p.name(rdata[0].text)
+		if err != nil {
+			return err
+		}
+		rec.CNAME = cname
+	case "URI":
+		if len(rdata) != 3 {
+			return errors.New("expected URI <priority> <weight> \"<target>\"")
+		}
+		priority, err := strconv.ParseUint(rdata[0].text, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid URI priority '%s'", rdata[0].text)
+		}
+		weight, err := strconv.ParseUint(rdata[1].text, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid URI weight '%s'", rdata[1].text)
+		}
+		rec.URI = &URI{Priority: uint16(priority), Weight: uint16(weight), Target: rdata[2].text}
+	default:
+		//other types only mark their name as existing
+		rec = nil
+	}
+	if rec != nil {
+		for _, other := range p.z.records[p.owner] {
+			if (other.Type == "CNAME") != (rtype == "CNAME") {
+				return fmt.Errorf("%s has a CNAME and other records", p.owner)
+			}
+		}
+	}
+	p.z.add(p.owner, rec)
+	return nil
+}
+
+//name returns the canonical form of a domain name,
+//relative names are within the current origin
+func (p *zoneParser) name(n string) (string, error) {
+	if n == "@" {
+		n = p.origin
+	} else if !strings.HasSuffix(n, ".") {
+		if p.origin == "" {
+			return "", fmt.Errorf("relative name '%s' without an $ORIGIN", n)
+		}
+		n += "." + p.origin
+	}
+	n = canonical(n)
+	if n == "" {
+		return "", errors.New("empty name")
+	}
+	return n, nil
+}
+
+//parseZoneTTL parses seconds, or BIND-style units (e.g. 1h30m)
+func parseZoneTTL(s string) (uint32, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	total, n, digits := uint64(0), uint64(0), false
+	for _, c := range strings.ToLower(s) {
+		if c >= '0' && c <= '9' {
+			n = n*10 + uint64(c-'0')
+			digits = true
+			continue
+		}
+		unit := map[rune]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}[c]
+		if unit == 0 || !digits {
+			return 0, fmt.Errorf("invalid TTL '%s'", s)
+		}
+		total += n * unit
+		n, digits = 0, false
+	}
+	if digits || total > 1<<31-1 {
+		return 0, fmt.Errorf("invalid TTL '%s'", s)
+	}
+	return uint32(total), nil
+}
+
+//lexZone splits a zone file into its logical lines,
+//removing comments and unescaping \X and \DDD
+func lexZone(src string) ([]zoneLine, error) {
+	lines := []zoneLine{}
+	cur := zoneLine{num: 1}
+	num, parens := 1, 0
+	startLine := true
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			num++
+			i++
+			if parens == 0 {
+				if len(cur.tokens) > 0 {
+					lines = append(lines, cur)
+				}
+				cur = zoneLine{num: num}
+				startLine = true
+			}
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			if startLine && parens == 0 && len(cur.tokens) == 0 {
+				cur.blank = true
+			}
+			i++
+		case c == ';':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '(':
+			parens++
+			i++
+		case c == ')':
+			if parens == 0 {
+				return nil, fmt.Errorf("line %d: unbalanced ')'", num)
+			}
+			parens--
+			i++
+		default:
+			quoted := c == '"'
+			if quoted {
+				i++
+			}
+			b := []byte{}
+			closed := false
+			for i < len(src) {
+				c := src[i]
+				if quoted && c == '"' {
+					closed = true
+					i++
+					break
+				} else if !quoted && strings.IndexByte(" \t\r\n;()\"", c) >= 0 {
+					break
+				} else if c == '\n' {
+					num++
+				} else if c == '\\' && i+1 < len(src) {
+					i++
+					c = src[i]
+					if i+2 < len(src) && isDigit(c) && isDigit(src[i+1]) && isDigit(src[i+2]) {
+						n, _ := strconv.Atoi(src[i : i+3])
+						if n > 255 {
+							return nil, fmt.Errorf("line %d: invalid escape '\\%s'", num, src[i:i+3])
+						}
+						c = byte(n)
+						i += 2
+					}
+				}
+				b = append(b, c)
+				i++
+			}
+			if quoted && !closed {
+				return nil, fmt.Errorf("line %d: unterminated string", num)
+			}
+			cur.tokens = append(cur.tokens, zoneToken{text: string(b), quoted: quoted})
+		}
+		startLine = false
+	}
+	if parens > 0 {
+		return nil, errors.New("unbalanced '('")
+	}
+	if len(cur.tokens) > 0 {
+		lines = append(lines, cur)
+	}
+	return lines, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
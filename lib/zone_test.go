@@ -0,0 +1,157 @@
+package subfwd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testZone = `$TTL 1h30m
+; comment
+@        IN  SOA ns.lvh.me. admin.lvh.me. ( 1 ; serial
+             3600 600 86400 60 )
+subfwd-go    TXT  "https://golang.org"  ; trailing
+subfwd-split 60 IN TXT "w=1 url=https://a.com"
+             IN 30 TXT "w=1 url=https://b.com"
+subfwd-long  TXT ( "https://ex" "ample.com/\065\"q" )
+_subfwd.uri  URI 10 1 "https://uri.com"
+alias        CNAME subfwd-go
+chain        CNAME alias.lvh.me.
+subfwd-*.w   TXT "nope"
+*.w    TXT "v=subfwd1; url=https://wild.com/$SUBDOMAIN"
+exists       A 127.0.0.1
+_subfwd.exists A 127.0.0.1
+$INCLUDE inc.db other.test.
+$ORIGIN sub.lvh.me.
+subfwd-x TXT x
+`
+
+//writeZone writes a zone file with the given name
+func writeZone(t *testing.T, dir, name, zone string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(zone), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestZone(t *testing.T) {
+	dir := t.TempDir()
+	path := writeZone(t, dir, "lvh.me.zone", testZone)
+	writeZone(t, dir, "inc.db", "subfwd-i TXT \"https://inc.com\"\n")
+	z, err := NewZoneResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"subfwd-go.lvh.me":    "https://golang.org",
+		"SUBFWD-GO.lvh.me.":   "https://golang.org",
+		"subfwd-split.lvh.me": "w=1 url=https://a.com|w=1 url=https://b.com",
+		"subfwd-long.lvh.me":  `https://example.com/A"q`,
+		"alias.lvh.me":        "https://golang.org",
+		"chain.lvh.me":        "https://golang.org",
+		//wildcards match every prefix of names which do not exist
+		"_subfwd.foo.w.lvh.me":  "v=subfwd1; url=https://wild.com/$SUBDOMAIN",
+		"subproxy-foo.w.lvh.me": "v=subfwd1; url=https://wild.com/$SUBDOMAIN",
+		"_subfwd.exists.lvh.me": "",
+		"subfwd-i.other.test":   "https://inc.com",
+		"subfwd-x.sub.lvh.me":   "x",
+		"missing.lvh.me":        "",
+	} {
+		txts, err := z.LookupTXT(name)
+		if got := strings.Join(txts, "|"); got != want || (want == "" && !isNotFound(err)) {
+			t.Errorf("%s: got %q %v, want %q", name, got, err, want)
+		}
+	}
+	_, ttl, _ := z.LookupTXTTTL("subfwd-split.lvh.me")
+	_, ttl2, _ := z.LookupTXTTTL("subfwd-go.lvh.me")
+	if ttl != 30*time.Second || ttl2 != 90*time.Minute {
+		t.Errorf("got %s %s", ttl, ttl2)
+	}
+	if cname, err := z.LookupCNAME("chain.lvh.me"); cname != "subfwd-go.lvh.me." {
+		t.Errorf("got cname %q %v", cname, err)
+	}
+	if uris, _, err := z.LookupURI("_subfwd.uri.lvh.me"); len(uris) != 1 || uris[0].Target != "https://uri.com" {
+		t.Errorf("got %v %v", uris, err)
+	}
+}
+
+func TestZoneErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, zone := range []string{
+		"x TXT \"open",
+		"$ORIGIN",
+		"rel TXT a",
+		"$ORIGIN a.\nx CNAME y\nx TXT z",
+		"a. TXT (x",
+		"a. 1q TXT x",
+		"a. CH TXT x",
+		"$INCLUDE bad.db",
+	} {
+		if _, err := NewZoneResolver(writeZone(t, dir, "bad.db", zone)); err == nil {
+			t.Errorf("%q: expected an error", zone)
+		}
+	}
+	if _, err := NewZoneResolver(); err == nil {
+		t.Error("expected a missing zone files error")
+	}
+}
+
+func TestZoneServer(t *testing.T) {
+	dir := t.TempDir()
+	path := writeZone(t, dir, "lvh.me.zone", testZone)
+	writeZone(t, dir, "inc.db", "")
+	z, err := NewZoneResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t, Config{Resolver: z, Naming: "both"})
+	for u, want := range map[string]string{
+		"http://go.lvh.me/":    "https://golang.org",
+		"http://foo.w.lvh.me/": "https://wild.com/foo.w",
+		"http://uri.lvh.me/":   "https://uri.com",
+	} {
+		if w := do(s, "GET", u); w.Header().Get("Location") != want {
+			t.Errorf("%s: got %d %q, want %q", u, w.Code, w.Header().Get("Location"), want)
+		}
+	}
+	if _, err := New(Config{Zones: []string{path}}); err != nil {
+		t.Error(err)
+	}
+	if _, err := New(Config{Zones: []string{path}, DNS: "1.1.1.1"}); err == nil {
+		t.Error("expected zones and dns to be exclusive")
+	}
+	if _, err := New(Config{Zones: []string{path}, DNSSEC: "validate"}); err == nil {
+		t.Error("expected zones and dnssec to be exclusive")
+	}
+}
+
+//TestZoneExample checks the example of the ZoneResolver docs
+func TestZoneExample(t *testing.T) {
+	path := writeZone(t, t.TempDir(), "example.com.zone", `$TTL 60
+_subfwd.go    TXT  "https://golang.org"
+_subfwd.docs  URI  10 1 "https://example.com/docs"
+*.links       TXT  "v=subfwd1; url=https://example.com/$SUBDOMAIN"
+`)
+	z, err := NewZoneResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t, Config{Resolver: z, Naming: "underscore"})
+	for u, want := range map[string]string{
+		"http://go.example.com/":      "https://golang.org",
+		"http://docs.example.com/":    "https://example.com/docs",
+		"http://a.links.example.com/": "https://example.com/a.links",
+	} {
+		if w := do(s, "GET", u); w.Code != 302 || w.Header().Get("Location") != want {
+			t.Errorf("%s: got %d %q, want %q", u, w.Code, w.Header().Get("Location"), want)
+		}
+	}
+	//with legacy naming, the wildcard is also a proxy record
+	s, _ = newTestServer(t, Config{Resolver: z})
+	if res, err := s.resolve("a.links.example.com"); err != nil || res.Rule == nil || !res.Proxy {
+		t.Errorf("got %+v %v", res, err)
+	}
+}
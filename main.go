@@ -12,15 +12,50 @@ import (
 var VERSION = "0.0.0-src"
 
 type config struct {
-	Port string `help:"listening port" env:"PORT"`
+	Port          string `help:"listening port" env:"PORT"`
+	subfwd.Config `type:"embedded"`
 }
 
 func main() {
-	c := config{Port: "3000"}
+	c := config{
+		Port: "3000",
+		Config: subfwd.Config{
+			AppDomain:          "subfwd.jpillora.com",
+			AdminHosts:         []string{"abc.example.com:3000"},
+			CNAMEs:             []string{"subfwd.herokuapp.com"},
+			Prefix:             "subfwd",
+			ProxyPrefix:        "subproxy",
+			Redirect:           302,
+			Naming:             "legacy",
+			DNSSEC:             "off",
+			RulesOrder:         "file",
+			CacheSize:          10000,
+			CacheTTL:           time.Minute,
+			NegativeTTL:        30 * time.Second,
+			StaleTTL:           time.Hour,
+			ReadHeaderTimeout:  10 * time.Second,
+			ReadTimeout:        10 * time.Second,
+			WriteTimeout:       10 * time.Second,
+			IdleTimeout:        2 * time.Minute,
+			StreamIdle:         5 * time.Minute,
+			FlushInterval:      100 * time.Millisecond,
+			Forwarded:          "x-forwarded",
+			TrustedProxies:     []string{"127.0.0.0/8", "::1/128"},
+			ProxyIdleConns:     32,
+			ProxyIdleTimeout:   90 * time.Second,
+			ProxyDialTimeout:   10 * time.Second,
+			ProxyTLSTimeout:    10 * time.Second,
+			ProxyHeaderTimeout: 30 * time.Second,
+			ProxyHTTP2:         true,
+		},
+	}
 	opts.New(&c).Version(VERSION).Parse()
 
 	rand.Seed(time.Now().UnixNano())
-	s := subfwd.New()
+	s, err := subfwd.New(c.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	log.Fatal(s.ListenAndServe(c.Port))
 }
@@ -345,7 +345,7 @@ func cssThemesDefaultAssetsImagesFlagsPng() (*asset, error) {
 	return a, nil
 }
 
-var _indexHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xa4\x59\x7b\x6f\xe3\x36\x12\xff\xbf\x9f\x62\x2a\x1c\x9a\xa4\xb0\xad\xee\xf6\x70\x40\xb3\xb2\xef\x82\x4d\xf6\x81\xdb\x6e\xb6\x49\xf6\xb6\x45\x51\x14\x63\x72\x2c\x31\xa6\x48\x2d\x49\xd9\x31\xb2\xf9\xee\x07\x92\x92\x2d\xcb\xce\x63\xb1\x7f\xd9\x22\x87\xf3\x9e\xdf\x0c\xa5\xec\xfb\xd3\xf3\x97\x57\x7f\x7c\x38\x83\xc2\x95\x72\xf2\x5d\x16\x7f\x00\xb2\x82\x90\xfb\x3f\x00\x99\x13\x4e\xd2\xc4\xd6\xd3\xd9\x92\x67\x69\x7c\x8a\x3b\x25\x39\x04\x85\x25\x8d\x93\x85\xa0\x65\xa5\x8d\x4b\x80\x69\xe5\x48\xb9\x71\xb2\x14\xdc\x15\x63\x4e\x0b\xc1\x68\x18\x1e\x06\x20\x94\x70\x02\xe5\xd0\x32\x94\x34\x7e\x36\xfa\x69\x00\x25\xde\x88\xb2\x2e\xbb\x4b\xb5\x25\x13\x9e\x71\x2a\x69\xac\x74\xd2\xc8\x93\x42\xcd\xc1\x90\x1c\x27\xd6\xad\x24\xd9\x82\xc8\x25\x50\x18\x9a\x8d\x13\x66\x6d\x6a\xa9\x44\xe5\x04\x1b\x95\x42\x8d\x98\xb5\x4f\x3d\x87\x55\xd5\x25\x0f\x44\x93\x3f\x55\x3e\x64\x52\xe3\xfc\x2f\xb8\x05\x2e\x6c\x25\x71\x75\x0c\x4a\x2b\x82\xbb\x2c\x8d\x34\x0d\x3d\x33\xa2\x72\xf1\x01\x20\x4d\x5f\x9f\x34\x7f\x97\x42\x71\xbd\x1c\xbd\xd6\x3a\x97\x74\xa2\x50\xae\x9c\x60\xf6\x7c\x7a\x4d\xcc\xc1\x18\x0e\x72\x3c\x78\xb1\x4d\x9a\x23\x8c\x3b\xff\xbf\x7c\x81\x59\xad\x98\x13\x5a\xc1\xe1\x11\xdc\x36\xc4\x87\x6b\x8a\xd1\xe7\x2e\xfd\xe8\xb3\x3f\xf1\xe7\x5f\x47\xa3\xaa\xb6\xc5\x21\x9a\xbc\x2e\x49\x39\x7b\xd4\x9c\xbb\x1b\x74\x68\x25\x8c\xe1\x19\xfc\x08\x8a\x96\x70\x8a\x8e\x0e\x8f\x5a\x5d\xa2\x55\xe9\xc6\xac\x2c\x6d\xd3\x21\x9b\x6a\xbe\x02\x95\x0f\xb1\xaa\xc6\x49\xcc\x89\x04\x82\xa7\xd0\xda\x71\x72\x2b\x35\x72\xe2\xc7\xe0\x4c\x4d\x77\x09\x34\xcb\x0d\x61\xe3\x2f\x2e\x16\xed\x46\x2d\xda\x7c\x01\x4b\xb9\x57\x36\x69\xfd\xd8\x23\x73\xba\x02\x74\x0e\x59\x41\x1c\x24\x4e\x49\xae\x29\x7d\xba\x3e\xef\x90\x7a\x65\xc9\x24\x93\xcb\x7a\xca\x75\x89\x42\xc1\x2b\x6d\x96\x68\xb8\xcd\xd2\xe2\xf9\x9a\x7f\xca\xc5\x62\x4b\x98\xe0\xe3\xc4\x32\x43\xa4\x6c\xb4\x49\x2b\x67\xb4\x94\x64\xc6\xc9\xaf\xa8\x30\x27\xf3\x72\xbd\xd4\x95\xde\xd1\xd4\x92\xab\xab\xce\xde\x8e\x1d\xb9\x11\x7c\x6b\x7f\x9b\x22\xaf\x05\x27\x30\x7a\xd9\xa3\xe9\x49\x11\x37\x8e\x48\xc1\xd2\x13\x33\x2d\xeb\x52\xed\xd0\x03\x64\xd5\x24\x9b\xee\xf5\xc2\x74\x02\x28\xa5\x5e\x5a\x58\xe9\x1a\x9c\xf6\x15\x07\xa7\xef\x2f\xfd\x5f\x66\x08\x1d\x81\x2d\xb4\x71\xf0\xf1\xe2\x9d\x85\x43\x29\xe6\x04\x19\x36\x15\x53\x38\x57\x1d\xa7\x69\x70\x03\x1f\x5d\x57\x42\x4a\x6d\x70\xc4\x74\x99\x80\x43\x93\x93\x1b\x27\x7f\x4f\x25\xaa\x79\x32\xd9\x43\x94\xa5\x38\x39\xca\xd2\x6a\xc7\xbe\x6e\x3c\xee\x5f\xf2\x5e\x50\xf9\x50\xcc\xc6\x89\x56\x6f\xc8\xe8\x79\xbd\x4e\x33\xa9\x73\xa1\x1e\x75\x9e\x5b\x92\x5c\xd0\x63\xbe\xeb\x1c\x98\xc9\x5a\x70\xa8\x05\x08\xa6\x15\x08\x55\xd5\x6e\xcf\x09\x80\x2c\x6c\x81\x5b\x55\x34\x4e\x1c\xdd\xb8\x04\x2a\x89\x8c\x0a\x2d\xb9\xcf\xa1\x33\xe5\xc8\x80\x2b\x7c\x7c\xb5\x83\x26\x2a\x3e\x02\x4b\x61\x0b\xef\xfb\x59\x8c\x10\xcc\x8c\x77\xa6\xca\x87\xa5\xe6\x1e\xb6\x22\x69\x02\xe4\x39\x34\x49\x76\x78\x94\x4c\x32\xd1\xad\x3d\x4b\x68\x58\x71\x0c\xdf\x87\xfd\xf3\xf9\x00\x58\x41\x6c\x5e\xa2\x99\x1f\x43\xb3\xb6\xa9\x49\x6f\x4c\x32\xc9\x52\xb1\xc7\xf8\x5d\xbf\xdf\xb7\xd8\x75\x93\xae\xcd\x63\x5e\x9d\xd6\xce\x69\xd5\x07\x0c\xa1\xf2\xe3\xe6\x77\xe0\xa1\xd6\xc3\x3e\x3f\x86\xef\x1b\x0f\x7d\xf9\xd2\xd1\x5e\xe5\xc3\x96\xc2\x07\x3c\x1c\xf2\x14\xbb\xc4\x0d\x2e\x09\x36\xdf\x78\x6c\x27\xa4\x95\x11\x25\x9a\x15\x44\xc5\x92\xc9\xa5\x27\xcc\xd2\xf8\xf8\x6d\x29\x1a\x9a\x47\x9b\xab\x8d\x4e\x6b\xf5\xce\x8c\x59\x2b\x53\x92\xb5\x98\x3f\x50\xf5\xdb\x3c\x92\xaf\x44\x81\x6d\xfc\xb1\x35\x63\x64\x2d\x34\x42\xf7\x26\xf2\x1f\x3e\x92\x8d\x3f\x85\x05\x43\xc8\x57\x23\xb8\xd2\x90\x93\x03\xeb\xd0\x38\xe2\x03\x90\x3a\x07\xa1\x9c\xf6\x19\x6c\x02\x7a\x94\x11\x24\x01\x15\x07\xe4\x1c\xae\x7e\xbf\x02\x43\x4c\x1b\x6e\x7d\x6a\x03\x21\x2b\xc0\xae\xf1\x68\x4f\xe6\x8f\xb2\xa9\xd9\x5b\x5a\xfb\x97\x5f\x79\xa6\x37\x58\x56\x92\xfc\x6c\x01\xda\x70\x5f\x61\x6b\x76\xf7\x71\x7b\x02\x94\x4d\x32\xa6\x39\xb5\x00\x76\x7b\xdb\xfa\xe3\xee\x2e\x4b\xc3\x8e\xc7\x31\x70\xfa\xdb\xd8\x37\x14\x2b\x5d\xbb\x7a\x34\xa5\x94\xff\xb6\xfc\xe7\xf2\x97\x4f\xf9\xef\xec\xb7\x8e\x98\xc1\xd7\x79\xc5\xfb\xd5\x8a\xb2\x92\xab\x16\xcc\xb1\x13\x8a\xfb\x4e\x45\x85\x62\xa7\x1e\xde\x6f\x36\x2c\x85\x2b\xe0\xc9\xda\xef\x93\xf5\x95\xf0\xd2\xcd\xfe\x50\x36\x2a\x1f\xda\xa5\x70\xac\xe8\x2e\x7e\x7d\x4d\xac\xd9\x0c\x97\x05\xa9\x71\xf2\xfe\xfc\xef\x97\xef\x4f\x7e\x3d\x4b\x3a\xd5\x22\xd4\x4c\x3f\x58\x2a\x1f\x24\xa1\xa5\x8d\x9f\x0f\xa2\x67\x7e\xdc\xe7\xba\x03\x3f\xb8\x49\xce\x3c\xc2\xc7\xbd\x23\x08\x12\x1f\x89\xcc\xb2\x10\xac\x80\x4a\x0b\xe5\xac\x4f\xee\x83\x6e\xa8\x7a\xbd\x35\x0a\x7a\x9a\xcb\xef\xf1\xc3\xa7\x8b\xf3\xf7\xaf\x77\x5d\x41\xc6\x68\xf3\xa0\x2f\xde\x2a\xa6\x8d\xf1\xb3\x6d\xb4\x6a\x81\xb2\xa6\x07\xb3\x6d\xaf\x9b\xfc\xe8\x51\x4b\x1e\x0d\xf6\xf6\x3e\x66\xee\x37\x59\xfb\xf1\xe2\xdd\xdf\x67\x17\x17\xe7\x17\x0f\xd8\xfa\x56\x2d\x50\x0a\xee\xa7\xa1\xaf\x61\x7d\x7a\xfe\xeb\xc9\xdb\xf7\x4f\xe6\x9e\x4d\x27\x7e\x2e\x08\xd3\x59\xf4\xc9\xd7\x08\x7b\x73\x76\x71\xfe\xdf\x8f\x8f\x0a\xfb\xa8\x7c\xeb\xf4\x5e\x0d\xa5\x13\xb1\x3b\x4a\x1b\xc1\x55\x21\x2c\x2c\x85\x94\xa0\x19\xab\x0d\x88\x19\x38\xbf\xb4\x69\x05\x28\x43\x33\x00\x74\x10\x67\xaf\xd1\xd3\x74\xe4\x34\xc3\x5a\xba\x87\xf4\x9a\x2b\xbd\x54\xcd\x72\x72\x7b\xbb\x6e\x93\x70\x77\x97\x3c\x15\x31\x76\x96\x7a\x0b\xfd\xc7\xed\xd6\x38\x45\x2b\xd8\xce\x3d\x24\x10\x56\x2d\xd9\x54\x4b\x9e\x4c\xde\xe8\x25\x70\x4d\x16\x84\x83\xa5\x36\xf3\x7f\xf7\xe6\xd9\x4c\xcb\x9e\x62\x52\xec\x4c\xad\x71\xd6\xe8\x46\x00\x70\xaa\x17\x34\xca\x52\x29\x76\x8e\x4f\xfe\xd0\xf5\x06\x66\x7c\xb7\x5d\x63\xc9\x61\x53\x4c\x4d\x39\xb4\xa0\x42\xca\x99\x55\xac\x22\xe2\x3e\x62\x8f\xd5\xd1\x3d\x82\x4f\xa4\xf4\xb9\xa9\xb4\x1a\x96\xe8\x58\x21\x54\x1e\x72\x34\x4a\xf9\x5c\x93\x11\xd4\xa4\xcd\x94\xc0\x92\x59\x10\x87\xe9\x0a\x1a\x41\xfb\x99\xf6\x62\xf9\xa9\x20\x05\x08\x86\x3e\xd7\x64\x1d\xa0\x31\x62\x41\x76\x10\x86\x65\x6f\xab\x6f\x5f\xd1\x9e\xad\x36\xf5\x83\x74\x2f\xa2\xeb\x7e\xc8\xdd\x8b\xfd\x70\xd0\x2a\x26\xb5\x9e\x13\x87\xba\x0a\xe3\x89\x98\xf9\xd8\xf9\x1b\x1e\x0a\x65\x01\x7d\x6d\x47\x71\xb5\x25\xb3\x3e\xd4\xcc\x11\xc4\xe1\xf0\xe7\x9f\x9e\x1f\x79\x02\x43\xa3\x5e\xce\x6d\x9b\x93\xa5\xdb\xc1\xef\xe7\xce\xa7\x62\x05\x5c\xc3\x92\x40\x91\x8f\x0b\x54\x86\x66\xe2\x66\x27\x85\x7a\x17\xa4\x4b\xa1\x18\x6d\x62\xee\x9d\xe1\xdd\x8e\x86\x40\x2b\xb9\x02\x43\xae\x36\x8a\x38\x78\x38\x88\x7a\xfa\x82\x0d\x81\x0b\xe1\x0a\xb1\x1b\x78\xb9\x65\x6d\xdd\x16\xef\xa8\xc1\xda\xc9\x31\x9c\xeb\x2e\xdf\xf8\xba\x45\x67\xa7\xa1\xc4\x39\x81\xad\xbd\x04\x17\xeb\x40\xe9\xe0\xcc\x99\x14\xcc\xc5\xb3\xde\x95\x9c\xac\x30\xc4\xb7\x64\xad\xc7\xbf\x6d\x2f\x6e\x39\xb0\xda\xb9\x3f\xdf\x77\xe3\x2b\xc2\xe3\xb0\x20\x59\xf5\x2f\xd5\x7b\xdc\x2e\x6c\xe3\x18\x6c\xd0\xcf\x3a\xaa\xfa\x8e\xdf\x75\x3d\xc0\x65\x70\x01\x98\x5a\x59\xd0\xaa\x85\xbe\xe6\xd7\x67\x8c\x05\x54\xf0\xe6\xea\xea\x03\x18\x5d\x3b\x7f\x25\xa9\x8c\xbe\x59\x8d\xe0\x9d\xbf\x10\xc7\x9d\x0f\x88\x97\x61\x9b\x8c\x8d\xb1\x77\x1a\xa6\xd4\x13\xd5\xc2\x73\x89\x15\x60\x8b\x0b\x0a\xcb\xb0\x88\x0a\xb0\xaa\xa4\x60\xe8\x84\x0e\x80\x4d\x86\x66\xda\x50\x4c\xdd\x8d\x51\x71\x6c\xff\x5c\x7b\xf7\xfb\x83\x42\x59\x32\x6e\x0b\x6b\xc2\xe8\xee\xe1\xbd\xaf\xc0\x46\xc0\x81\x85\xe4\x65\x6d\x9d\x2e\xe1\x34\x1c\xb2\x09\x48\x61\xdd\x08\xde\xce\x9a\xf2\x86\x02\x39\x08\x77\x60\xc1\xa3\xf7\xdb\x0f\x7e\xee\x37\xfe\x7e\x71\x28\x46\x34\xf2\x0a\x9f\xbd\x7c\xee\xc5\x3b\x54\x8c\x8e\x06\x3d\x61\xa1\xbd\x04\x85\x59\xe8\xf9\x53\x02\x43\xa5\x5e\x10\x1f\xf5\x28\x7b\x35\x57\x3d\x80\xf2\xbb\xc1\x3f\xbb\x71\x06\x61\x46\xe8\x6a\x43\xb6\x7f\xba\xde\x05\xeb\x7e\x02\x34\xaf\x4e\x7c\x60\xbd\xa3\x63\x61\x34\x4d\x6d\x3d\xb6\xac\x2f\x36\x28\xad\x06\x64\xce\x02\x7a\x68\x99\xa1\x94\x53\x64\xf3\xbe\x49\x97\x1a\x50\x4a\x28\x85\xb5\x9e\xf1\xa6\xf4\xa4\x0c\x2f\x64\xee\x95\x14\x91\xf0\x70\x33\xd3\xf7\x3b\x63\xb7\x6e\xb7\xcf\x1e\x3d\x0c\x5e\xfb\xc1\x59\xb8\x22\x5e\xd7\x4c\xf3\x82\x27\xbc\x17\x1a\x84\x8b\x06\x43\x9f\x4a\x4c\xd6\x3c\x2a\x3c\xd3\x52\xea\xa5\xb7\x67\x81\x46\xf8\x6c\xee\x27\x58\x1c\x67\x5b\x84\xc5\xda\xe9\x12\x9d\x60\x28\x65\xe8\x19\xd6\x09\x57\x37\x1d\xab\x7d\x1f\xe2\x44\x49\xc7\x7d\x23\xfb\x61\x6b\xb5\x8f\xd6\xff\xe3\xed\x87\xd6\x5d\xc3\xb6\xaf\x90\x39\xb0\x9d\x1c\xdd\x35\xbe\xc7\xe2\xf4\xe4\xea\x6c\xc3\xa4\x56\xe2\x06\xa8\xd2\xac\xf0\x57\xcd\x52\x48\x29\x2c\x31\xad\xf8\xe3\x8c\xde\x9c\x9d\x9c\x9e\x5d\xfc\x99\x89\x89\xef\x99\x59\x2a\x26\x7f\xed\x28\xd7\xbc\xb6\xf4\xf8\x6d\x08\x42\x6f\x8d\x78\xd5\x6e\x78\x10\xd8\x27\x29\x4b\x6b\x39\xb9\x32\x2b\xd0\xb5\x8b\x05\xd5\x5c\x89\x77\x2e\xa2\x0b\x34\xf6\x91\x17\x76\x3b\x24\xfe\xfe\xd9\x13\x78\x28\x94\xad\xa8\x05\xfa\xc6\x44\x60\xb5\x91\xb0\xe7\x78\x4c\xbb\x3d\xd7\x80\x6c\x3a\xf9\x84\x46\xb5\xe3\xc4\x7b\x1d\x66\x89\x15\x54\x68\xb0\x24\x47\x06\xc8\x32\xac\x7c\x2a\x09\x0b\x15\x99\x99\x36\x65\x1f\x18\x9e\x94\xc0\x1f\x6d\x5b\xb8\x4d\xa7\x5b\x17\x48\x40\xe9\x75\x6b\xf3\x30\x45\xc8\x41\xcf\xf6\xb7\xbe\x90\xb3\xe1\x88\x67\xd6\x13\xc2\xc9\x3a\xa1\x02\x6e\xfa\xf2\xe8\x32\x9b\x75\xd0\x43\x83\x70\x8f\x0d\x10\xf5\x83\x03\xc4\x95\xa8\x76\x20\xac\xdf\xc1\xba\x81\xb7\xc7\x69\xba\x5c\x2e\x47\x4c\xea\x9a\xcf\x24\x1a\xf2\x81\x49\x2b\x89\x1e\xcf\xfb\x09\xf0\xd2\x53\xbd\xf2\x54\xe1\x05\x87\xb0\x50\x88\xbc\x08\x03\x06\xd3\x65\x49\x8a\xf7\x43\xd0\x34\xc7\xd0\x05\x37\xa7\x0f\x2c\xcc\x0c\x11\x38\x41\x26\xbc\xfc\x09\x4f\xeb\xb7\x44\x7e\xc4\xde\x66\xb3\x39\xda\x7b\x37\xad\x2b\xef\xd3\x00\x0f\xc8\x9c\x58\xf8\x09\x58\x28\x2e\x16\x82\xd7\x28\x9b\x76\x16\x2e\xc6\x05\x2e\x02\x08\x95\x21\x48\xc2\xb7\xbe\xc2\xe8\x3a\x2f\xb6\x04\xb9\x82\x84\xf1\x37\x7b\x83\xd6\x99\x9a\xf9\x9e\x30\x68\x50\xc9\xcf\x50\xa1\xc5\x5f\x5e\xbe\x1b\x00\x5a\x4b\x0e\x4a\xa1\xc4\xac\xe9\x88\x03\x60\x18\x26\xe0\x01\x9c\x9e\x9e\x5f\x7a\x39\x8e\xe2\xf7\x19\x3f\x55\x6a\x3f\x5e\xc0\x94\x14\xcd\x84\xb3\xa3\xfb\x86\x9a\xed\x4f\x0f\x3b\xdf\x21\x3a\x37\x12\xed\x7c\xf3\xbd\xff\xbb\x47\xb5\x79\xf9\xaa\x1d\x99\x64\x92\xd9\x0a\x55\x77\x31\x99\xbc\x5a\xcf\xb0\xb7\xb7\x6d\x2a\x5a\xb8\xbb\x6b\x81\xc7\x66\xa9\x3f\xd4\x1c\x6d\x26\xad\xba\xf2\x98\x9b\x6c\x73\xfa\x58\x79\x16\x71\x2b\xdc\xd7\xe3\xb9\x7e\xb2\xe5\xc2\x15\xf5\x34\x24\x59\x0b\x05\x69\xfb\xa9\xa8\x97\x6e\x3b\x9a\xce\xbd\xf3\x5f\x07\x06\x3e\xff\x9e\xc2\xfb\x11\xa6\xff\x69\xe9\x02\xbf\x5d\xf7\xfc\xc0\x74\xb5\x7a\x01\xcf\x7f\x7a\xf6\x2f\x68\x2d\xda\x04\xab\x13\x9d\xee\xdf\xf8\x6d\x0c\xac\x61\xe3\x24\x56\x57\x1e\x3e\xf2\x0d\xb1\xfd\xca\x17\x54\xdc\x3c\x5d\xdb\x04\x38\xcd\xc8\x4c\xba\x1f\xd6\x76\x38\xe1\x35\xde\x34\xac\xb0\x12\x0d\x93\x6b\xbc\x49\xa5\x98\xda\x14\x55\x5e\x4b\x34\xd7\x36\x7d\x36\xfa\x79\xf4\x4b\xfb\x1c\x3e\x77\x5e\xdb\xe4\x61\xd6\x8c\xab\x91\xc1\x65\x2e\x5c\xe0\x7a\x66\x04\xfb\xf4\xbf\xd7\xaf\xd3\x93\xc8\xe4\x52\x0a\x4e\xa1\x5d\xa7\x25\xfa\x56\xd9\x72\xdf\x6c\x3c\x26\xe3\x3a\x7e\x49\xed\x53\x65\xe9\x54\xf3\xd5\xe4\xbb\x2c\x0d\x5f\x97\xff\x1f\x00\x00\xff\xff\x16\x39\x3d\x46\x74\x1e\x00\x00")
+var _indexHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xa5\x5a\x6d\x73\xdb\x36\x12\xfe\x9e\x5f\x81\x72\x3a\x8d\xdd\x91\xa5\x24\x77\xd3\x99\x3a\xb2\x7a\x3e\x5b\x49\x3c\x6d\x6d\xd7\x96\x9b\x66\x32\x99\x0c\x44\x42\x22\x22\x92\x60\x00\xd0\xb2\x26\xf1\x7f\xbf\x67\x01\x52\xa2\x48\xc9\x72\x2e\x9f\x2c\x82\x8b\x7d\xdf\x07\xbb\xa0\xfb\x3f\x9c\x5e\x9c\x8c\xde\x5d\x0e\x59\x6c\xd3\x64\xf0\xa4\xef\xff\x30\xd6\x8f\x05\x8f\xe8\x07\x7e\x5a\x69\x13\x31\x30\xc5\x78\x32\x8f\xfa\x3d\xff\xe4\xdf\xa4\xc2\x72\x96\xf1\x54\x1c\x05\xb7\x52\xcc\x73\xa5\x6d\xc0\x42\x95\x59\x91\xd9\xa3\x60\x2e\x23\x1b\x1f\x45\xe2\x56\x86\xe2\xc0\x3d\x74\x98\xcc\xa4\x95\x3c\x39\x30\x21\x4f\xc4\xd1\xf3\xee\xb3\x0e\x4b\xf9\x9d\x4c\x8b\xb4\xbe\x54\x18\xa1\xdd\x33\x1f\x63\x29\x53\x41\x29\x2f\x91\xd9\x8c\x69\x91\x1c\x05\xc6\x2e\x12\x61\x62\x21\x20\x30\xd6\x62\x72\x14\x84\xc6\xf4\x8c\x48\x79\x66\x65\xd8\x4d\x65\xd6\xc5\xc2\x63\xf7\xf1\x3c\xaf\x93\x3b\xa2\xc1\xfb\x6c\x7a\x10\x26\x8a\xcf\x3e\xb0\x2f\x2c\x92\x26\x4f\xf8\xe2\x90\x65\x2a\x13\xec\xbe\xdf\xf3\x34\x25\x7d\xa8\x65\x6e\xfd\x03\x63\xbd\xde\xeb\xe3\xf2\xe7\x5c\x66\x91\x9a\x77\x5f\x2b\x35\x4d\xc4\x71\xc6\x93\x05\x94\x33\x17\xe3\x4f\x22\xb4\xec\x88\x3d\x9d\xf2\xa7\x2f\xd7\x49\xa7\x1c\xeb\xab\xdf\x5f\xbf\xb2\x49\x91\x85\x56\xaa\x8c\xed\xed\xb3\x2f\x25\xf1\xde\x92\xa2\xfb\xb9\x4e\x8f\x27\xec\x78\xff\x61\xbf\x9b\x17\x26\xde\xe3\x7a\x5a\xa4\x08\x85\xd9\x2f\xf7\xdd\x77\x6a\xb4\x09\x76\x3e\x67\x3f\xb3\x4c\xcc\xd9\x29\xb7\x62\x6f\xbf\xd2\xc5\x5b\xd5\x5b\x99\xd5\xef\x55\xe9\xd0\x1f\xab\x68\xc1\xe0\x19\xb8\x0c\xde\x74\x39\x11\x30\xe7\x29\x6e\xcc\x51\xf0\x05\x0e\x8b\x44\x74\xc8\xac\x2e\xc4\x3d\x92\xc1\x2f\x97\x84\xa5\xbf\x22\x79\x5b\xbd\x28\x64\x95\x2f\xcc\x88\x29\x29\x1b\x54\x7e\x6c\x90\x59\x95\x33\x6e\x2d\x0f\x63\x11\x31\x24\x86\x48\x96\x94\x94\xae\x2f\x6a\xa4\xa4\xac\xd0\xc1\xe0\xba\x18\x47\x2a\xe5\x32\x63\xaf\x94\x9e\x73\x1d\x19\x18\xf2\x62\xc9\xbf\x07\x01\x6b\xc2\x64\x04\x4d\x43\x2d\x44\x66\xbc\x4d\xd0\x4c\xab\x24\x11\xfa\x28\xf8\x93\x67\x7c\x2a\xf4\xc9\x72\xa9\x2e\xbd\xa6\xa9\x11\xb6\xc8\x6b\xef\x5a\x76\x4c\xb5\x8c\xd6\xde\xaf\x53\x4c\x0b\x19\x09\xa6\xd5\xbc\x41\xd3\x90\x22\xef\x2c\xd4\x44\x34\x41\x1c\xaa\xa4\x48\xb3\x16\x3d\x76\xe4\x83\xfe\x78\xa3\x17\xc6\x03\xc6\x93\x44\xcd\x0d\x5b\xa8\x02\xae\xa5\x8a\x63\xa7\xe7\xd7\xf4\x13\x0e\x40\x36\x30\x13\xa3\x9a\xd9\xcd\xd5\x1f\x86\xed\x25\x72\x26\x58\x9f\x97\x15\x13\x5b\x9b\x1f\xf6\x7a\xce\x0d\x51\xf7\x53\x2e\xc1\x49\xf3\x6e\xa8\xd2\x80\x59\xe4\x9c\x40\xed\x7f\x1c\x27\x3c\x9b\x05\x83\x0d\x44\xfd\x1e\x1f\xec\xf7\x7b\x79\xcb\xbe\x7a\x3c\xb6\x2f\x91\x17\x10\x1b\x09\x45\x54\xf6\x46\x68\x35\x2b\x96\x69\x96\xa8\x29\xcc\xdc\xe5\x3c\x3b\x17\xc9\xad\xd8\xe5\xbb\xda\x86\x49\x82\xa0\x30\xc4\x4e\x22\x23\x00\x61\x79\x61\x37\xec\xc0\x1e\xf7\x8a\xd9\x45\x0e\x40\xb4\xe2\x0e\x18\x03\xd0\x08\x45\xac\x92\x88\x72\x68\x88\x44\xd7\xcc\xc6\x14\x5f\x65\x59\x19\x15\x8a\xc0\x5c\x9a\x98\x7c\x3f\xf1\x11\x62\x13\x4d\xce\x84\x99\xa9\x8a\x08\xb6\x3c\x69\xc0\x04\x71\x28\x93\x6c\x6f\x3f\x18\xf4\x65\xbd\xf6\x8c\xe0\x3a\x8c\x0f\xd9\x0f\xee\xfd\xc5\xac\xc3\x50\x2c\xe1\x2c\xe5\x7a\x76\xc8\xca\xb5\x55\x4d\x92\x31\xe0\xd0\x93\x1b\x8c\x6f\xfb\x7d\xdb\x62\xdd\x4d\xaa\xd0\xbb\xbc\x3a\x2e\xac\x85\x0f\x1b\x80\x21\xb3\xe9\x61\xf9\xb7\x43\x50\x4b\xb0\x0f\x0c\xf9\xa1\xf4\x10\x30\x6d\xa5\x3d\xb6\x56\x14\x14\x70\xb7\x89\x28\xda\xc4\x25\x2e\xc9\x70\xb6\xf2\x58\x2b\xa4\xb9\x96\xf0\xcf\x82\x79\xc5\x80\x19\x44\x88\x02\x71\x8f\xdf\x97\xa2\xee\xf0\xa8\x72\xb5\xd4\x69\xa9\xde\x50\xeb\xa5\x32\xa9\x30\x06\xe0\xb2\x3d\x71\xd7\x79\x04\xdf\x88\x02\xeb\xf8\x63\x8a\x30\x84\x3c\x56\x0a\xdd\x98\xc8\xef\x28\x92\xa5\x3f\xa5\xc1\xc9\xc9\xa3\x45\x97\x8d\x14\x43\x6d\x33\x83\x1a\xb7\x22\xea\x30\x54\x1b\x8a\x01\x59\xbb\x20\x72\x42\x8f\xd4\x83\x24\xe3\x59\xc4\x78\x14\xb1\xd1\x3f\x23\x6c\x0e\x15\x30\x87\x52\x9b\x09\xa0\x37\x14\x18\x6f\xcf\xfc\x6e\x7f\xac\x37\x96\xd6\xe6\xe5\x57\xc4\xf4\x8e\xa7\x79\x22\xa8\xb7\x60\x90\x44\x15\xb6\x64\xb7\x8d\xdb\x23\xa0\x6c\xd0\x0f\x51\x7b\x15\x80\x7d\xf9\x52\xf9\xe3\x1e\x87\xbf\x7b\x43\x38\x06\x51\xdf\xc7\xbe\xa4\x80\x27\x6c\xd1\x1d\x8b\x5e\xf4\xd7\xfc\xdf\xf3\x5f\xdf\x4e\xff\x09\xff\xaa\x89\xe9\x7c\x9b\x57\xc8\xaf\x46\xc2\x29\x8b\x0a\xcc\x79\x2d\x14\xdb\x76\x79\x85\x60\x68\x0e\xdd\xe5\x1d\x0c\x3d\xd8\x6e\x3c\xe2\x66\x63\xf6\x68\x1b\x36\x49\xfc\x46\x90\xa9\xd7\x80\x2b\x1e\x2c\x18\x68\x11\xc6\xf5\xc5\x6f\xaf\x8c\x25\x9b\x83\x79\x2c\xb2\xa3\xe0\xfc\xe2\xe3\xc9\xf9\xf1\x9f\xc3\xa0\x56\x33\x32\x9b\xa8\x07\x0b\xe6\x32\x11\x1c\x27\xe8\xd2\xdb\x4f\xbd\x67\x7e\xde\xe4\xba\xa7\xd4\xbe\x25\x51\x48\x38\xef\xdf\xed\x33\x27\x71\x47\x7c\xe6\xb1\x44\xf5\xe4\x0a\x25\x67\x28\xc5\x9f\x2e\x03\x86\x5e\xec\xb4\x29\xe4\x71\xee\xde\xe2\x83\xb7\x57\x17\xe7\xaf\xdb\x6e\x10\x5a\xa3\xde\x1e\xf2\xc3\x59\x06\x0b\x34\x75\xb7\xde\xa2\x5b\x9e\x14\xe2\xc1\x7c\xdb\xe8\x22\x6a\x3e\x8a\x24\xf2\xc6\x92\xad\x0f\x99\xfa\x5d\x96\xa2\xbf\xf9\x38\xbc\xba\xba\xb8\x7a\xc0\xce\xb3\x0c\x66\xe0\xb4\x00\xed\xb7\xb0\x3e\xbd\xf8\xf3\xf8\xec\xfc\xd1\xdc\xd1\xa9\x51\x57\xe0\x7a\x33\xef\x8f\x6f\x11\xf6\x66\x78\x75\xf1\xfb\xcd\x4e\x61\x37\x19\x1d\x9c\xe4\x51\x57\x32\x1e\xb9\xbd\x34\xe0\x7b\x0c\xac\x47\x6a\x26\x4c\x85\x21\xd6\xe5\x04\xbd\x0a\x96\x56\x07\x01\x4f\xdc\x51\x80\x3e\x9c\xf9\xce\xab\xfb\x38\x1d\x23\x31\xe1\x45\x62\x1f\xd2\x6b\x96\xa9\x79\x56\x2e\x07\x08\x74\x55\xd2\x88\x73\xf0\x58\xa4\x68\x2d\x35\x16\x9a\x8f\xeb\x07\xe3\x98\x1b\x19\xb6\xa6\x10\xdf\x47\x57\x64\x63\x74\x72\xc1\xe0\x8d\x9a\xc3\x29\xc2\x30\x69\xd9\x5c\xe9\xd9\x6f\x8d\x6e\xb6\xaf\x92\x86\x62\x89\x6c\xf5\xac\xbe\xd3\xa8\x47\x80\xf1\xb1\xba\x15\x70\x69\x22\x5b\xdb\x07\x38\x92\x57\xf0\x42\x67\xed\x12\x43\xf6\xca\x42\x2a\xcb\xa1\x02\x13\x18\x81\xb6\xc6\x55\x10\x06\x26\x44\xec\xa1\x1a\xda\x22\xf4\x18\xb9\x80\xbc\xc4\xcc\x7b\x90\x72\x04\x12\xad\x96\xcb\x4f\x2f\xe1\x73\x21\xb4\x14\x65\xca\x8c\x31\x31\x08\x7d\x0b\x51\xe3\x05\xf3\xe3\xde\x16\xa6\x8d\x38\xbe\x45\xfe\xc2\x24\x2d\xc0\xce\x58\xc6\xb5\x96\xb7\xc2\x74\x5c\x9b\x4c\x76\xd2\xc1\xe5\x6d\xd9\x70\x40\xfd\x94\xd8\x97\xde\x79\x3f\x4d\xed\xcb\xcd\x80\x50\xa9\x97\x28\x35\x83\x7a\xf0\x39\xb5\x27\x48\x6e\x44\x8f\x26\x3c\x6c\x46\x66\x53\x75\x7b\xa1\x74\xf9\xb0\xdc\x54\xf6\x11\xd8\xb7\x07\xc1\x5a\x44\xd2\xa1\xdb\xfd\xfd\x3e\xd1\x6a\xd1\x6d\x24\xe0\xba\x7d\xfd\xde\x7a\x26\x34\x13\xe9\x6d\xbc\x40\xec\xd9\x5c\x60\x08\xa7\x20\x95\x96\xb5\xf2\xa9\x31\x2b\x5d\xcb\x2c\x14\xab\x04\x20\xef\x50\x1c\xb8\x16\x4c\x65\x38\xf4\x35\x32\x4b\x67\x60\x48\xd8\xe0\xf5\xa4\xea\x75\x91\x74\xf1\x73\xc1\xec\x90\xdc\xb4\x30\x76\x8d\x77\xe9\xdb\xca\xeb\x3e\xbe\xcb\xa3\x7e\xcd\xf9\x15\x58\x03\x4d\x52\x8e\x01\xd1\x14\x24\xc7\xfa\xd2\xc8\x94\xf3\xee\x04\xed\xb7\xf5\x1c\xc8\xb7\x91\x30\x70\x60\xb4\x26\x71\xd9\x0f\xae\xfb\x72\xcd\x8d\x79\x6b\xa0\xde\x36\x02\xc6\xee\xf1\x20\x16\x49\xde\x9c\xb2\x37\x38\x1f\x5e\xf1\xee\xe1\x25\x20\x1a\x2b\xf2\xdf\x5a\xc3\x69\xbf\x35\xac\x5e\xbb\x0c\x67\xba\x40\xee\x60\x9e\x29\xd1\xb0\xfc\x4b\x29\x84\x70\x60\x79\x34\xba\x44\x57\x5f\x58\x9a\x51\x72\xad\xee\xd0\x45\xff\x41\x13\xb2\x7f\x73\xc9\xf9\xb5\x7b\x2d\xb4\xf1\x19\x00\x57\x8e\x45\x43\x54\x85\xd8\x29\x47\xe6\x56\x50\x41\x97\x6e\xb4\x08\x21\x28\x67\x38\x99\xd3\x1d\x11\x61\x38\xac\x41\xd2\x0a\x9f\xcb\x2b\xa3\x7c\x1f\xff\xb9\x20\xf7\xd3\x46\x24\xbd\xc0\x7c\x5f\x87\x1f\xd7\xcb\x13\xe2\x37\x15\x58\x09\x78\x6a\x58\x70\x82\x94\x51\x29\xf3\x10\x62\x02\x96\x48\x63\xbb\xec\x6c\x52\x56\x3d\x8b\x39\xaa\xcb\x82\x92\x00\xfd\xec\x92\x06\x01\x4d\x03\xc7\x9e\xec\x8a\x2e\x29\x3c\x3c\x79\x41\xe2\x2d\x47\x16\xef\x77\x1a\xc2\xdc\x89\xe3\x14\x0e\x5d\x0b\x80\x1a\xd4\x22\x05\x2e\x46\xdd\x06\x65\xa3\xf2\xf2\x07\x80\xbf\x1d\xfc\xe1\x9d\xd5\x9c\x4d\x80\xa7\x48\x5a\xd3\xdc\x5d\x24\x3b\x61\xab\xbc\x4b\xa1\xc0\x92\xa3\x7d\x79\x94\xe7\xdc\xb2\x8b\x59\x4e\x3a\x3c\x31\x88\x55\x88\xc6\x8d\x13\xd6\x4c\x78\x92\x8c\x79\x38\x6b\x9a\x74\xad\xe8\x5a\x86\xa5\xd2\x18\x62\xbc\x2a\x40\x2c\xd2\x0d\xcd\x56\x49\x1e\x20\xf7\x56\x4d\xfe\x93\x9d\xbd\xfd\x3a\x87\xfd\x87\x81\x6c\x33\x72\xa3\xaa\xfd\x14\xa7\xcb\x7b\x1f\x77\x5d\xd4\x71\xf3\x47\xc8\x29\xa1\xc2\xa4\x88\xbc\xda\x13\x45\xf7\x4d\x64\xd5\x2d\xd7\x92\x72\xba\x99\x66\xbe\xbf\xad\x80\x97\x17\x48\x32\xe4\x5c\x08\x87\xb8\x03\xc5\x58\x69\x8b\xf2\x28\xab\xae\x49\xac\x4c\xc5\x61\xd3\xd4\x66\xf0\x2a\xed\xbd\x0f\x7e\x3c\xbb\xac\x9c\x76\x50\x1d\x3a\x42\x23\x5b\x57\x99\xda\x36\xbe\xc1\xe2\xf4\x78\x34\x5c\x31\x29\x32\x38\x54\xe4\x0a\xca\xc3\x1b\x29\xf4\x97\x06\x31\xc8\xa2\xdd\x8c\xde\x0c\x8f\x4f\x87\x57\xef\xfb\x72\x40\x07\x2a\x5d\xc5\x7c\x68\x29\x57\xde\x66\x12\x96\x03\xa7\xdc\xc1\xeb\x51\xab\x7a\x41\x50\xb0\x53\xd2\x5f\x37\xc3\xab\x77\x5b\x04\xd1\x39\x8e\x4e\x81\x6b\x30\xb2\x1b\x25\xad\xde\x3d\x4a\xd8\xc9\xc5\xc5\xef\x67\xc3\x2d\xd2\x42\x1c\xc3\x52\x6c\x10\x52\xbe\x78\x94\x84\x37\x17\xd7\xa3\x0d\x9e\x52\xc6\xee\xdc\x7a\x7d\xf3\x5f\xdf\x99\xb7\xf7\x2f\xeb\x75\x27\x93\xcb\xe3\xd1\x9b\xf6\xfe\x9c\xdb\x78\xe7\xd6\x9b\xe3\xcd\xf9\xe7\xda\x0e\x34\xc3\xd9\x6e\x13\x6e\x6e\xce\x4e\x57\x4c\xd0\x3b\xa1\xa1\x01\x22\xfb\xe5\x9d\xb1\xb9\x39\x1f\x5d\xbd\xab\xb6\x53\x2b\xb4\x7c\x73\x3e\x3a\x3b\x1f\x9e\x8f\x36\xeb\x07\x38\x76\x20\x43\x3b\xa8\x6d\x92\x19\x5d\xda\x13\x25\xa0\x7d\xee\x9b\xb8\xa9\x50\x2c\xe2\x96\x8f\x69\x18\x96\xc6\x35\x00\x72\x0a\x84\x8d\xf6\x37\x29\xd6\xef\xa1\x56\x47\xe0\xa9\xe8\xd6\x94\x80\xbf\xbc\xcb\x69\xdd\xa0\x00\x33\xcc\x8e\x9b\xe6\x16\x09\x5d\x9c\x34\x04\xee\xe1\xd8\xc9\x45\xd5\x90\x94\x76\x33\xcc\x3a\x09\xdb\xb0\xdd\x03\xe3\x86\xe9\xf5\xc6\x54\x30\xfc\xe3\x17\x24\xf9\xdf\xc7\x57\x94\xe3\x5f\xf1\x73\x92\xd1\xaf\xfb\x5a\x67\x04\xa8\x02\x8c\xf9\x41\x78\xad\x95\x02\x1a\x0a\x5d\x12\x76\xca\xb5\x22\xcf\x57\x6b\x1b\x21\xbc\x44\xed\x43\xc8\xf2\xb3\x35\xc4\x55\xd2\x30\x39\x95\x44\x98\x12\x88\x22\xe1\x74\x27\x53\x27\xd9\x13\xdd\x69\x77\xa9\x3b\xe1\xd8\x57\x47\xfc\xe2\xd9\xb3\x5f\x0e\x9e\x3d\x3f\x78\xf6\xe2\x7e\x79\x20\x6c\x30\xfc\x6f\x6f\x05\x35\x9a\xc2\x84\x3c\x07\x1a\xd3\xb5\x5e\x09\x11\x96\xa9\x89\xfb\x8d\x53\x80\xfe\x2e\x1c\xa1\xcc\xdc\xf7\xbc\x4d\x0e\xd3\x7c\x5e\x77\x55\x79\xad\xeb\x59\xe3\xa4\xf8\x3f\x8e\xa4\x1b\x53\x1d\xc8\xe5\x41\x57\x3b\xf8\xd0\x7d\x5d\xb6\x7a\x57\xea\x43\x00\xa3\xa4\xf9\x03\x1d\xae\x3b\x94\x1c\x07\xe2\xdd\x90\x89\x02\x40\x31\xb8\xf6\xc8\x59\x5e\x63\x39\xa9\x35\x09\x68\xb9\xec\xae\x69\xa1\x78\x70\x5a\x18\xc9\xdc\xec\x98\x0c\xd6\xea\xc6\xa0\x70\xe6\xf3\x79\x37\x4c\x54\x11\x4d\x12\x04\x83\xf2\xba\x97\xa3\x5e\x4c\xbb\x7e\x4e\x88\xea\x15\x51\xb9\x8b\x4d\x14\x63\x2c\xa7\xb1\x9b\x26\xb0\x0b\x33\x71\xd4\x6c\xc1\xca\x1e\xd8\x35\xbb\xab\xdd\x80\x8a\x89\x16\x38\xf4\x25\xf0\x8c\xb2\xc3\x3d\x2d\x6f\x87\x69\xb8\x5e\x67\xb3\xda\xda\xf8\x26\xa5\x72\xf2\xa9\x3b\xff\xd1\x3a\xc9\x5b\x9a\x7d\x65\x86\xc6\x4e\x46\x05\x4f\xca\xae\xd5\x5d\x85\xc5\xfc\xd6\x75\x19\xa9\x0b\x92\xa4\x0e\x37\x46\x5f\x3d\x8d\x9f\xac\xb7\x96\x42\x6a\xba\xcb\xd3\xdc\x58\x5d\x84\xd4\xfa\x75\xca\xb6\x83\x06\x26\xd7\xc9\x5f\x5f\x63\x0c\x84\xd3\xd1\xcc\xa4\x32\x93\x93\xb2\xf1\xed\xa0\xa1\x71\xf3\x6f\x87\x9d\x9e\x5e\x5c\x93\x1c\x2b\xfc\x77\x59\x02\x44\x45\x53\x04\xda\x96\x0c\x99\x63\x4d\x77\xdb\xec\xb2\xfe\xc9\xb1\xf5\xfd\xb1\x76\x17\xa1\x2c\xf5\xd8\xdb\xbf\x77\xe6\xab\x8f\x2e\x50\x44\x07\x83\xbe\xc9\xd1\x71\xd5\x16\x83\xc1\xab\xe5\xec\x8a\xa4\x2e\x53\xd1\x20\xad\x2b\x58\x47\x32\xd1\xa6\x72\x6b\x39\x50\x15\x39\x35\x55\xc1\x3a\xa7\x9b\x9c\x58\xf8\x57\xee\xd2\xc0\xef\x6b\x26\xdb\x14\xe8\x56\x8c\x5d\x92\x55\x48\xda\xab\x3e\x11\x37\xd2\xad\xa5\xe9\x8c\x9c\xff\xda\x31\xa0\xfc\x7b\x0c\xef\x1d\x4c\xff\x53\xd1\x39\x7e\x6d\xf7\xfc\x14\xaa\x7c\xf1\x92\xbd\x78\xf6\xfc\x17\x56\x59\xb4\x0a\x56\x2d\x3a\xf5\x9f\xfe\x9b\x38\x33\x3a\x3c\x0a\x7c\x75\x4d\xdd\xc7\xfd\x03\x5e\x7d\xdd\x77\x2a\xae\x9e\x3e\xa1\xd4\x00\xda\x42\x0f\xea\x1f\xd4\x5b\x9c\xf8\x27\x7e\x57\xb2\x02\xf6\x95\x4c\xb0\x06\x88\x18\x1b\xb0\x9b\x16\x28\x90\x4f\xa6\xf7\xbc\xfb\xaf\xee\xaf\xd5\xb3\xfb\x37\x07\x08\x78\x98\x75\x18\x65\x5d\x20\x2d\x1c\xe8\xb8\x0e\xb5\x0c\xdf\xfe\xfd\xfa\x75\xef\xd8\x33\xb9\x4e\x64\x24\x5c\x3f\xde\x4b\x39\x9d\xf5\x15\xf7\xd5\x8b\x5d\x32\x3e\xf9\xff\xa0\x68\x52\xf5\x7b\xf4\xcf\x02\x83\x27\xfd\x9e\xfb\xaf\x92\xff\x01\x69\x57\xfb\x13\x6c\x22\x00\x00")
 
 func indexHtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -360,7 +360,7 @@ func indexHtml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "index.html", size: 7796, mode: os.FileMode(420), modTime: time.Unix(1493910093, 0)}
+	info := bindataFileInfo{name: "index.html", size: 8812, mode: os.FileMode(420), modTime: time.Unix(1792311874, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -385,7 +385,7 @@ func jsAngularMinJs() (*asset, error) {
 	return a, nil
 }
 
-var _jsAppJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x9c\x57\x5f\x6f\xdb\x36\x10\x7f\xcf\xa7\xb8\x09\x46\x25\xaf\x8a\xe6\xa0\x5d\xbb\xd5\x10\x86\x60\x0b\xd0\x74\x5b\x33\x20\xe8\x93\x97\x07\x46\x3a\xc9\xaa\x69\x52\x20\x29\x7b\xc6\xea\xef\x3e\xf0\x8f\x24\xca\x92\xdd\xa1\x7e\x91\xc4\xe3\xdd\xfd\xee\x0f\x7f\x3c\x47\x45\xc3\x32\x55\x71\x16\xcd\xe1\xdf\x2b\x80\x1d\x11\x70\x5b\xd7\xcb\xab\x2b\xd0\x4f\x48\x61\x5f\xb1\x9c\xef\x13\xfb\x41\x58\xd9\x50\x22\x92\x2d\xcf\x1b\x8a\x51\x28\x9b\xe7\x62\x9f\x87\x31\xac\x9e\xe6\xad\x4e\x92\x71\xa6\x04\xa7\x14\x45\x14\xfe\x49\x18\x29\x51\xfc\xda\x2d\x85\x31\x74\x2e\x67\x82\x73\xf5\x98\xf1\x1a\x63\x98\x49\xf7\xb4\xfe\x62\x98\xad\x95\xaa\x63\x98\xa9\x6a\x8b\xbc\x51\x31\x64\x9c\x49\x4e\x31\x06\xa9\xb8\x20\x25\x5a\xc0\x16\xb2\x51\x5e\x9a\x4f\xf3\x0a\x29\xf4\xc6\x93\x6d\x29\xf4\xc2\xe9\xa6\x24\xe7\x5b\x52\x31\x48\x21\x08\xfc\xe5\xd9\x9e\xa8\x6c\x1d\x05\x56\x1c\x78\x80\x5b\x97\x00\x02\x55\x23\x98\x53\x90\xa8\x9a\xfa\x61\x03\x29\x14\x84\x4a\xe7\xe2\x38\xf7\x6d\x52\x4e\xf2\x8a\x95\xc3\x2d\x63\x23\x5a\x3e\x76\x76\xc1\xcb\x40\x78\x27\x84\x17\xcc\xd8\xb5\x12\x4d\xa7\x66\xd2\x9b\x94\xa8\xa2\xe0\x07\xa3\xfc\x8b\x0d\x37\x0d\xe0\xe5\x20\x3d\xf3\x44\x36\x59\x86\x52\x9e\xb6\x8a\xfd\x95\x24\x0a\x25\x32\xdd\x03\x21\xee\x90\x29\xfd\xf2\x68\x62\x79\xf8\x3d\x8c\x87\xa6\x96\x9d\xda\x99\xfc\xf9\x08\x8f\xf3\x04\x85\xe0\xa2\xf7\x8b\x42\xfc\x4f\xd7\x77\x5a\xf1\xc4\x7b\x0c\x5a\xbf\x87\xe0\xfa\xc9\x39\x19\xca\xc6\xf0\x6c\x6e\x51\x88\x1e\xde\x2a\x28\x2a\x46\x28\x3d\x04\x4f\xd3\xb9\x19\x58\x99\xec\x80\xbe\x4d\x8e\xfa\x71\xec\x0f\x51\x5e\x09\xcc\x54\xb5\xc3\x28\x40\xa6\x50\x4c\xb5\xa1\xb3\xdf\xad\xbb\x23\x84\x14\xb7\xc8\x54\x0c\x44\x29\x21\x7b\x40\x6e\x3d\x79\xae\x58\x1e\x05\x1b\x3c\xe4\x7c\xcf\x60\x83\x87\x5a\xa0\x94\xbe\x03\x93\x4d\x3f\x92\xaa\x00\xbb\x98\xec\xd7\x55\xb6\x86\xef\xd2\x14\x6e\x5e\xf9\x3b\x5a\x34\x7d\x0e\x8f\xdd\x9b\x3b\x56\xa4\xae\xe9\x61\x3a\x53\xdd\x1e\xdc\x11\x1a\x19\xdc\x89\x09\xdb\xab\xc9\x71\x5c\x1f\x0b\xa9\x16\xe6\xf9\x1b\x16\xa4\xa1\x2a\x9a\x7f\x2d\xb5\x05\xc9\x14\x17\x87\x28\x74\x1d\x30\xa0\x24\x4b\x3f\x3e\xb7\x64\x9a\x71\x5c\xd9\x75\xcb\x65\x02\x89\xd2\x4a\xe1\xa7\xdb\xeb\x57\x3f\xbd\x5d\xfc\xfc\xf6\xcd\xcd\xf5\xcd\x8f\x7a\x85\x34\x8a\x87\xf3\x7e\x6f\xdb\x9e\x35\x29\x71\x57\xe1\xbe\x95\x49\x54\xf7\x3a\x3c\x1d\xed\x54\x46\x5c\x7c\x93\x1d\xfe\x57\xc5\xca\xd6\xce\x71\x1e\xc3\x9b\x05\x7c\x0f\x37\x8b\xc5\xa2\xb5\xad\x84\x4f\x23\x44\x94\x72\x64\xf8\x56\x08\x72\x48\x6a\xc1\x15\x57\x07\xdd\xe2\xb4\xca\x30\xc9\x08\xa5\x76\x7f\xf2\x99\x57\x2c\x0a\x21\xf4\x53\x08\x90\x69\x1a\x75\x17\x82\x4b\xde\x80\xc9\x5a\x2f\x94\x97\xef\xa6\x88\x0c\x20\x4b\x28\x2f\x13\xdb\x09\x59\x0c\x44\x94\x8d\xee\x49\x39\xae\xed\x64\xec\x7f\xf0\x32\x34\xe5\x88\x7a\xcd\xbe\xde\x71\xdb\xe8\xfa\x44\x9f\x05\x60\xa4\xdf\x0e\xa1\xa3\x96\x33\x20\x2e\xb7\x9c\xbb\xbb\xc2\x89\xd3\x6c\xee\x31\x2b\x8e\x61\x2f\x48\x6d\x4d\xea\x37\xbf\x9e\x4c\xc6\x50\xb0\x51\x45\x27\x83\xed\xf0\xad\x16\x4f\x90\xc2\x4a\xeb\xfa\x6b\x4f\xae\xce\xd7\xe1\x38\xf8\x82\xb9\x1c\xb1\x86\xd2\xa9\x34\x1d\x07\xad\xe1\x90\x43\xda\xb9\xb7\xe7\xe4\x9d\x8f\xdc\xc7\xa6\xc3\x2d\x58\x0c\x9b\x18\x64\xef\x5c\x6a\x03\xc7\xfe\xbb\xe0\x02\xa2\x0d\x54\xec\xf4\xd6\x77\x62\x7d\x7b\x3b\xc9\x6a\xf3\xb4\xf4\x09\x65\xb5\xd1\x31\xeb\xf4\xb5\x39\x9b\xe2\xa6\x96\xa3\x47\x4d\x54\xa2\xf2\xb0\x6f\xf0\x70\x0a\xbe\x23\x85\xfe\xd0\x51\x9e\x11\xfa\x68\xe1\xe8\xbb\xf5\x5e\xe1\xd6\xa8\x2e\x07\x4c\xaa\x37\xbf\x78\xa1\x75\x12\xd9\x3c\xeb\x46\x5a\xc4\xf0\x7a\x0e\x69\x9a\x42\xf0\x61\xf6\xf0\x31\x98\x62\x56\xf8\xf0\xf8\xf0\x31\xa9\x89\x90\x18\x79\xaa\xaf\xe7\x17\xe3\xea\x41\x76\x91\xc9\xd3\xc8\x62\xd8\x11\x7a\x4a\xf7\x9a\x17\x78\xa1\x25\x06\x57\xc8\x9f\x3f\x63\xa6\xc2\x21\x32\x23\x75\x98\xe1\x25\x44\x06\xa2\x54\xa2\x62\x65\x55\x1c\x22\x6d\xf6\x12\xba\x41\xbe\x64\x9f\x2f\x0b\x68\x04\x3c\x47\x7a\xbe\x24\x53\x26\x05\x6e\xf9\x0e\x47\x55\x38\xfa\x8d\xeb\xf4\x1c\xab\xf5\x6d\xec\xde\xce\x1d\xe4\x19\xfe\x93\x61\xad\x81\xbc\x27\x2c\x3f\x19\x6c\x1d\x37\x9e\xbb\xa7\x3b\xd5\x18\x32\xd2\x48\xaf\xa7\x87\x33\x49\x78\xd7\x6e\xd4\xfb\xca\xb5\xfa\x9b\x85\x31\x74\xda\x89\x54\x24\xdb\xc0\x97\x2f\xfd\x52\x17\xa2\xae\xe0\x89\xed\x0e\xc7\x25\x27\x52\x53\x93\x55\xbc\xcc\x68\xa2\x61\xd1\xe4\x20\xdf\x8d\xe9\x66\xc2\xfc\xca\x90\xee\xb2\xae\xd5\x07\x23\xbb\xbb\x6e\x1c\x50\xca\xcb\x28\xbc\x67\x95\x0a\x07\x33\x35\x67\xef\x51\xf0\x4d\x33\x1c\xa9\xac\xac\xa9\xf5\xff\x06\x48\x41\xb3\x97\x2f\x28\xb8\xd8\x13\x91\x6b\x9e\x59\xd8\xf5\xc1\x24\xac\x88\x92\xc1\xc4\xc8\x9b\x13\x45\x4e\x07\x72\xcf\xbf\x16\x27\xf6\x6b\x38\x7c\x77\x38\xcc\x8e\x4f\xe6\x6b\x39\xf5\x1f\xc2\xc3\x65\xb6\x3e\x5a\x04\xfe\x7f\x09\x93\xfd\xe3\xdc\x5e\xd3\x6a\x5d\x69\x2a\xfe\x2f\x00\x00\xff\xff\xa3\xd2\x26\x86\xc0\x0d\x00\x00")
+var _jsAppJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9d\x57\xdd\x6f\xdb\x36\x10\x7f\xef\x5f\xc1\x09\x41\x45\x6f\x8e\xe6\x2c\x5d\xbb\xd5\x08\x86\x60\x0d\xb0\x74\x6b\x53\xd4\xe8\x53\x96\x07\x46\xa6\x64\xc5\x34\x29\x50\x94\x1d\x63\xf5\xff\xbe\xe3\x87\x24\x52\x92\xdd\x61\x4f\x12\x79\x5f\xbf\x3b\x1e\xef\x8e\x38\xab\x79\xaa\x0a\xc1\xf1\x04\xfd\xf3\x02\xa1\x2d\x91\xe8\xba\x2c\xe7\x2f\xe0\x1f\xbe\xe8\x0a\xed\x0a\xbe\x14\xbb\xc4\x2e\x08\xcf\x6b\x46\x64\xb2\x11\xcb\x9a\x51\x1c\x57\xf5\x63\xb6\x5b\xc6\x53\x74\xff\x30\x69\x64\x92\x54\x70\x25\x05\x63\x54\xe2\xf8\x03\xe1\x24\xa7\xf2\xf7\x76\x0b\x78\x5b\x93\x67\x52\x08\xb5\x48\x45\x49\xa7\xe8\xac\x72\x5f\x6b\x0f\x7e\x56\x4a\x95\xf0\x51\xc5\x86\x8a\x5a\x4d\x11\xa8\xad\x04\x03\x96\x4a\x09\x09\x4a\x2d\x60\x0b\xd9\x08\xcf\xcd\xd2\xfc\x02\xd4\x4e\x79\xb2\xc9\xa5\xde\xe8\x33\x25\x4b\xb1\x21\x05\x07\x52\x14\xf9\xdb\x67\x3b\xa2\xd2\x15\x8e\x2c\x39\xf2\x00\x37\x26\x11\x92\x54\xd5\x92\x3b\x81\x0a\x16\xe5\xdd\x1a\x14\x65\x84\x55\xce\xc4\x61\xe2\xeb\x64\x82\x2c\x0b\x9e\x87\x2c\x43\x25\x9a\x3e\x34\x76\xc2\x4a\x40\xbc\x91\xd2\x73\x66\x68\x5a\xc9\xba\x15\x33\xe1\x4d\x72\xaa\x70\xf4\xa3\x11\xfe\xcd\xba\x7b\x15\xa1\x1f\x82\xf0\x4c\x92\xaa\x4e\x53\x5a\x55\x78\x04\x19\x42\x39\x81\x2c\xa0\x5c\xe7\x40\x4c\xb7\x94\x2b\xfd\xb3\x30\xbe\xdc\xfd\x09\xff\x81\xaa\x79\x2b\x76\x24\x7e\x3e\xc2\xc3\x24\xa1\x52\x0a\xd9\xd9\x85\xe5\x7f\x34\x7d\xa3\x05\x7b\xd6\xa7\x48\xcb\x77\x10\x5c\x3e\x39\x23\x21\x6d\x08\xcf\xc6\x16\xb8\x3a\x78\xf7\x51\x56\x70\xc2\xd8\x3e\x7a\x18\x8f\x4d\xa0\x65\x34\x03\xba\x34\x39\xe8\xcf\xa1\xbb\x44\xcb\x42\x52\xd0\xb8\xa5\x38\x02\xcf\xa8\x1c\x4b\x43\xa7\xbf\xdd\x77\x57\x88\x32\xba\x01\x99\x29\x22\x4a\xc9\xaa\x03\xe4\xf6\x93\x47\xb8\x61\x38\x5a\xd3\x3d\xdc\x33\x8e\xe0\x5b\x4a\x38\x5e\xdf\x80\x89\xa6\xef\x49\x91\x21\xbb\x99\xec\x56\x45\xba\x42\xdf\x5d\x5d\xa1\x8b\x4b\x9f\xa3\x41\xd3\xc5\xf0\xd0\xfe\xb9\x6b\x45\xca\x92\xed\xc7\x23\xd5\xf2\xd0\x2d\x61\xd8\xe0\x4e\x8c\xdb\xde\x99\x1c\x86\xe7\x63\x21\x01\x7c\xfd\x7d\x47\x33\x52\x33\x85\x27\xdf\x0a\x6d\x46\x52\xa8\x20\x7b\x1c\xbb\x0c\x08\x4a\x92\x2d\x3f\x7e\x6d\x49\x75\xc5\x71\xc7\xae\x53\x2e\x95\x94\x28\x2d\x14\x7f\xb9\x3e\xbf\xfc\xe5\xcd\xec\xd7\x37\xaf\x2f\xce\x2f\x7e\xd6\x3b\xa4\x56\x22\x9e\x74\xbc\x4d\x7a\x96\x50\xaf\xb6\x05\xdd\x35\x34\x48\xa9\x5b\xed\x9e\xf6\x16\x1f\x2f\x2f\xa3\x19\xfe\x09\xb2\xa8\xd1\x73\x98\x4c\xd1\xeb\x19\xfa\x1e\x5d\xcc\x66\xb3\x46\xb7\x92\x7e\x19\x21\x32\xaf\x06\x8a\xaf\xa5\x24\x7b\x08\x9c\x50\x42\xed\x75\x8a\xb3\x22\xa5\x49\x0a\xc9\x6c\xf9\x93\x27\x51\x70\x1c\xa3\xd8\x0f\x21\x5c\x19\x5d\x46\x5d\x43\x70\xc1\x0b\x2a\x59\x63\x85\x89\xfc\x2d\x1a\x3d\xe8\x14\xee\x41\x9e\xd8\x4c\x80\xc0\x82\xb1\x5a\xe7\x64\x35\x3c\xdb\x51\xdf\xff\x12\x79\x6c\x8e\x03\x77\x92\xdd\x79\x4f\x9b\x44\xd7\x37\xfa\x28\x00\x43\xfd\xff\x10\xda\xd2\x72\x04\xc4\xe9\x94\x73\xbd\x2b\x1e\xb9\xcd\xa6\x8f\x59\xf2\x14\xed\x24\x29\xad\x4a\xfd\xe7\x9f\x27\xaf\x40\x96\x0f\x4e\x74\xd4\xd9\x16\xdf\xfd\xec\x01\x74\xdc\x6b\x59\x7f\xef\xc1\x9d\xf3\x79\x3c\x74\x3e\xe3\x2e\x46\xbc\x66\x6c\x2c\x4c\x87\x20\x35\x1c\x72\xb0\xd2\x98\xb7\xf7\xe4\xad\x8f\xdc\xc7\xa6\xdd\xcd\xa0\x28\xaf\x21\x94\x9d\xf1\x4a\x2b\x38\x74\xeb\x4c\x48\x84\xd7\x08\xba\x74\xaf\xeb\x3b\xb2\xee\xde\x8e\x72\xbf\x7e\x98\xfb\x05\x05\xd6\x7a\x7c\x81\xf0\x35\x31\x1b\xab\x4d\x4d\x8d\x1e\x24\x11\xb4\x46\x0f\x3b\x54\xc9\x3e\xf8\xb6\x28\x74\x97\x8e\x09\xb8\x42\x0b\x0b\x47\xf7\xd6\x5b\x45\x37\x46\x74\x1e\x54\x52\xcd\xfc\xf2\xa5\x96\x81\xde\xfa\xa8\x13\x69\x36\x45\xaf\x26\xe8\x0a\xaa\x6a\xf4\xfe\xec\xee\x63\x34\x56\x59\xd1\xfb\xc5\xdd\xc7\xa4\x24\xb2\xa2\xd8\x13\x7d\x35\x39\xe9\x57\x07\xb2\xf5\xac\xea\x7b\x36\x05\x7f\x58\xbf\xdc\xeb\xba\x20\x32\x4d\x31\xb8\x62\xf1\xf8\x04\x0d\x29\x0e\x91\x19\xaa\xc3\x0c\x73\x03\x36\x10\xc1\x26\x94\xa8\x22\xdb\x63\xad\xf6\x14\xba\x20\x5e\x55\x17\x2f\x0b\x68\x00\x7c\x49\xd9\xf1\x23\x19\x53\x29\xe9\x46\x6c\xe9\xe0\x14\x0e\x7e\xe2\x3a\x39\x57\xd5\xba\x34\x76\x7f\xc7\x2e\xf2\x19\x7d\x4e\x69\xa9\x81\xfc\x41\xf8\xb2\x37\xd8\xba\xda\x78\xac\x4f\xb7\xa2\x30\xd4\x92\xba\xf2\x72\x3a\x9c\x49\xe2\x9b\x86\x51\xf3\xe5\x2b\xf5\x37\x07\x2b\xad\x34\x80\x25\xe9\x1a\x7d\xfd\xda\x6d\xb5\x2e\xea\x13\xec\xe9\x6e\x71\x9c\x32\x52\xe9\xd2\x64\x05\x4f\x57\x34\x59\x73\x3c\x3a\xc8\xb7\x63\xba\x99\x30\xbf\x31\xa4\xbb\xa8\x6b\xf1\x60\x64\x77\xed\xc6\x01\x85\x96\x81\xe3\x5b\x5e\xa8\x38\x98\xa9\x21\xf4\x54\x8a\x75\x1d\x8e\x54\x96\x56\x97\xfa\xdd\x00\x14\x5d\xbd\x7c\x02\x14\x94\x1d\x91\x4b\x5d\x67\x66\xfe\x3e\xd4\xba\x77\xed\x73\xc0\x3e\x6b\x92\xa7\xb2\x60\x0c\xb2\x00\x7a\xdd\x26\x78\x21\xc0\xc4\x91\x15\xcf\x1d\x67\x8f\x28\x9e\xf7\x9f\x7c\x0e\xb3\x13\xf0\x48\x6a\xe7\x3b\x60\xb8\x9c\xfd\x64\x29\xc1\x44\xae\x88\xaa\xa2\x91\xd1\x7b\x49\x14\xe9\x3f\x0c\xbc\x38\x68\x72\x62\x57\xe1\x23\xa0\x8d\x87\xe1\xf8\x62\x56\x21\x87\x17\x18\xc3\xb3\xb0\xa6\x43\x26\x3f\x4a\x86\xeb\xba\xd9\x08\xf9\xda\x00\x19\x26\x1b\x8c\x3e\x87\x1f\x25\xc7\xd6\x6e\xcd\xc7\x9e\x59\x5e\xd0\x0c\xff\x67\xb7\xf6\xdf\x5b\x26\x43\xe1\xe5\x60\x46\x19\xb5\x2a\x74\xbb\xfa\x17\xe3\x38\x22\x81\xe4\x0e\x00\x00")
 
 func jsAppJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -400,7 +400,7 @@ func jsAppJs() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "js/app.js", size: 3520, mode: os.FileMode(420), modTime: time.Unix(1493910093, 0)}
+	info := bindataFileInfo{name: "js/app.js", size: 3812, mode: os.FileMode(420), modTime: time.Unix(1792311874, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
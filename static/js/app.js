@@ -120,10 +120,18 @@
     scope.onHeroku = false;
     scope.uptime = null;
     scope.forwards = 0;
+    scope.appDomain = "subfwd.jpillora.com";
+    scope.prefix = "subfwd";
+    scope.proxyPrefix = "subproxy";
+    scope.redirect = 302;
     $http.get("/stats").success(function(data) {
       scope.onHeroku = data.Heroku;
       scope.uptime = data.Uptime;
-      return scope.forwards = data.Success;
+      scope.forwards = data.Success;
+      scope.appDomain = data.AppDomain;
+      scope.prefix = data.Prefix;
+      scope.proxyPrefix = data.ProxyPrefix;
+      return scope.redirect = data.Redirect;
     });
   });
 